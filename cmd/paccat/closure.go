@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"friedelschoen.io/paccat/internal/store"
+)
+
+func closureCommand(args []string) error {
+	flags := newFlags("closure")
+	args, err := parseFlags(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	resolved, err := filepath.EvalSymlinks(args[0])
+	if err != nil {
+		return err
+	}
+	pathname := store.TopLevel(resolved)
+	if pathname == "" || !store.IsValid(pathname) {
+		return fmt.Errorf("%s is not a valid store path", args[0])
+	}
+
+	closure, err := store.Closure([]string{pathname})
+	if err != nil {
+		return err
+	}
+	for _, current := range closure {
+		fmt.Println(current)
+	}
+	return nil
+}
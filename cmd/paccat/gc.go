@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"friedelschoen.io/paccat/internal/store"
+)
+
+var sizeSuffixes = []string{"B", "K", "M", "G", "T"}
+
+func parseSize(value string) (int64, error) {
+	value = strings.TrimSuffix(strings.ToUpper(value), "B")
+	multiplier := int64(1)
+	for i, suffix := range sizeSuffixes[1:] {
+		if strings.HasSuffix(value, suffix) {
+			value = strings.TrimSuffix(value, suffix)
+			multiplier = int64(1) << (10 * (i + 1))
+			break
+		}
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %s", value)
+	}
+	return size * multiplier, nil
+}
+
+func formatSize(size int64) string {
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(sizeSuffixes)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %siB", value, sizeSuffixes[i])
+}
+
+func gcCommand(args []string) error {
+	flags := newFlags("gc")
+	options := store.GCOptions{}
+	flags.BoolVar(&options.DryRun, "dry-run", false, "")
+	flags.BoolVar(&options.DryRun, "n", false, "")
+	maxFreed := flags.String("max-freed", "", "")
+	_, err := parseFlags(flags, args, 0, 0)
+	if err != nil {
+		return err
+	}
+	if *maxFreed != "" {
+		if options.MaxFreed, err = parseSize(*maxFreed); err != nil {
+			return &usageError{"gc", err.Error()}
+		}
+	}
+
+	lock, err := store.LockGC(true, func() {
+		fmt.Fprintln(os.Stderr, "waiting for running paccat processes to finish")
+	})
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	result, err := store.CollectGarbage(&options)
+	if result != nil {
+		verb := "deleted"
+		if options.DryRun {
+			verb = "would delete"
+		}
+		for _, pathname := range result.Deleted {
+			fmt.Printf("%s %s\n", verb, pathname)
+		}
+		fmt.Printf("%s %d store paths, %s freed\n", verb, len(result.Deleted), formatSize(result.Freed))
+	}
+	return err
+}
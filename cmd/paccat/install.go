@@ -0,0 +1,42 @@
+package main
+
+import (
+	"friedelschoen.io/paccat/internal/install"
+	"friedelschoen.io/paccat/internal/store"
+)
+
+func installCommand(args []string) error {
+	flags := newFlags("install")
+	options := evalFlags(flags)
+	prefix := flags.String("prefix", defaultPrefix(), "")
+	flags.StringVar(prefix, "p", defaultPrefix(), "")
+	args, err := parseFlags(flags, args, 1, 2)
+	if err != nil {
+		return err
+	}
+
+	value, err := evaluatePath(options, args)
+	if err != nil {
+		return err
+	}
+
+	db := install.PackageDatabase{Prefix: *prefix}
+	/* packages are known by their hash, as printed by `paccat hash` */
+	if err := db.Install(store.HashPart(value.Path), value.Path); err != nil {
+		return err
+	}
+	return store.AddProfileRoot(*prefix)
+}
+
+func removeCommand(args []string) error {
+	flags := newFlags("remove")
+	prefix := flags.String("prefix", defaultPrefix(), "")
+	flags.StringVar(prefix, "p", defaultPrefix(), "")
+	args, err := parseFlags(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	db := install.PackageDatabase{Prefix: *prefix}
+	return db.Remove(args[0])
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"friedelschoen.io/paccat/internal/store"
+)
+
+/* captureStdout returns what fn prints to stdout */
+func captureStdout(t *testing.T, fn func() error) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	err = fn()
+	os.Stdout = stdout
+	writer.Close()
+	output, _ := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return string(output)
+}
+
+func TestInstallRemove(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	prefix := t.TempDir()
+	recipe := filepath.Join(t.TempDir(), "hello.pcr")
+	err := os.WriteFile(recipe, []byte(`output { name = "hello", env = { PATH = "/usr/bin:/bin" }, script = ''mkdir -p {{ out }}/bin; echo hello > {{ out }}/bin/hello'' }`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	captureStdout(t, func() error { return installCommand([]string{"--prefix", prefix, recipe}) })
+	installed := path.Join(prefix, "bin/hello")
+	if _, err := os.Lstat(installed); err != nil {
+		t.Fatalf("%s is not installed: %v", installed, err)
+	}
+
+	/* the installed package is a root of the garbage-collector */
+	target, err := filepath.EvalSymlinks(installed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots, err := store.FindRoots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(roots, store.TopLevel(target)) {
+		t.Errorf("%s is not a root, roots are %v", store.TopLevel(target), roots)
+	}
+
+	hash := strings.TrimSpace(captureStdout(t, func() error { return hashCommand([]string{recipe}) }))
+	captureStdout(t, func() error { return removeCommand([]string{"--prefix", prefix, hash}) })
+	if _, err := os.Lstat(installed); !os.IsNotExist(err) {
+		t.Errorf("%s is still installed after removing %s", installed, hash)
+	}
+}
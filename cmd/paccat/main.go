@@ -1,14 +1,22 @@
 package main
 
 import (
-	_ "embed"
+	"embed"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path"
+	"slices"
+	"strings"
 
 	"friedelschoen.io/paccat/internal/ast"
 	"friedelschoen.io/paccat/internal/errors"
 	"friedelschoen.io/paccat/internal/parser"
+	"friedelschoen.io/paccat/internal/sandbox"
+	"friedelschoen.io/paccat/internal/store"
 	"friedelschoen.io/paccat/internal/types"
+	"friedelschoen.io/paccat/internal/util"
 )
 
 //go:embed cat.txt
@@ -17,6 +25,172 @@ var logo string
 //go:embed help.txt
 var helpmsg string
 
+//go:embed help/*.txt
+var helpfiles embed.FS
+
+type usageError struct {
+	command string
+	message string
+}
+
+func (this *usageError) Error() string {
+	return this.message
+}
+
+type command func(args []string) error
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"build":   buildCommand,
+		"closure": closureCommand,
+		"eval":    evalCommand,
+		"gc":      gcCommand,
+		"hash":    hashCommand,
+		"install": installCommand,
+		"remove":  removeCommand,
+		"help":    helpCommand,
+	}
+}
+
+func commandHelp(name string) string {
+	content, err := helpfiles.ReadFile("help/" + name + ".txt")
+	if err != nil {
+		return helpmsg
+	}
+	return string(content)
+}
+
+func newFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Usage = func() {}
+	return flags
+}
+
+/* parseFlags parses the options of a command and checks the number of remaining arguments */
+func parseFlags(flags *flag.FlagSet, args []string, minArgs, maxArgs int) ([]string, error) {
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil, err
+		}
+		return nil, &usageError{flags.Name(), err.Error()}
+	}
+	rest := flags.Args()
+	if len(rest) < minArgs || len(rest) > maxArgs {
+		return nil, &usageError{flags.Name(), "invalid number of arguments"}
+	}
+	return rest, nil
+}
+
+type stringList []string
+
+func (this *stringList) String() string {
+	return strings.Join(*this, ":")
+}
+
+func (this *stringList) Set(value string) error {
+	*this = append(*this, value)
+	return nil
+}
+
+/* evalFlags registers the options which control evaluation and building */
+func evalFlags(flags *flag.FlagSet) *types.Options {
+	options := &types.Options{}
+	flags.BoolVar(&options.Sandbox, "sandbox", false, "")
+	if paths := os.Getenv("PACCAT_SANDBOX_PATHS"); paths != "" {
+		options.SandboxPaths = strings.Split(paths, ":")
+	}
+	flags.Var((*stringList)(&options.SandboxPaths), "sandbox-path", "")
+	flags.IntVar(&options.MaxDepth, "max-depth", types.DefaultMaxDepth, "")
+	if search := os.Getenv("PACCAT_PATH"); search != "" {
+		options.SearchPath = strings.Split(search, ":")
+	}
+	/* entries given by -I are searched before $PACCAT_PATH */
+	included := 0
+	flags.Func("I", "", func(entry string) error {
+		options.SearchPath = slices.Insert(options.SearchPath, included, entry)
+		included++
+		return nil
+	})
+	return options
+}
+
+func defaultPrefix() string {
+	if prefix := os.Getenv("PACCAT_PREFIX"); prefix != "" {
+		return prefix
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return path.Join(home, ".paccat/profile")
+	}
+	return path.Join(os.TempDir(), "paccat-profile")
+}
+
+func selectAttribute(value types.Value, attribute string) (types.Value, error) {
+	if attribute == "" {
+		return value, nil
+	}
+	for _, name := range strings.Split(attribute, ".") {
+		var get func(string) (*types.Thunk, bool)
+		switch value := value.(type) {
+		case *types.AttrsValue:
+			get = value.Attributes.Get
+		case *types.PathValue:
+			get = value.Get
+		default:
+			return nil, fmt.Errorf("unable to get attribute `%s` of %s", name, value.TypeName())
+		}
+		thunk, ok := get(name)
+		if !ok {
+			return nil, fmt.Errorf("value has no attribute `%s`", name)
+		}
+		next, err := thunk.Force()
+		if err != nil {
+			return nil, err
+		}
+		value = next
+	}
+	return value, nil
+}
+
+/* gcLock prevents the garbage-collector from deleting outputs of this process, it is held until exit */
+var gcLock *store.Lock
+
+/* evaluateFile parses and evaluates filename and selects the attribute-path if given */
+func evaluateFile(options *types.Options, args []string) (types.Value, error) {
+	if gcLock == nil {
+		var err error
+		gcLock, err = store.LockGC(false, func() {
+			fmt.Fprintln(os.Stderr, "waiting for the garbage-collector to finish")
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	value, err := types.EvaluateFile(options, args[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 1 {
+		return selectAttribute(value, args[1])
+	}
+	return value, nil
+}
+
+/* evaluatePath evaluates like evaluateFile, the result must be a store-path */
+func evaluatePath(options *types.Options, args []string) (*types.PathValue, error) {
+	value, err := evaluateFile(options, args)
+	if err != nil {
+		return nil, err
+	}
+	result, ok := value.(*types.PathValue)
+	if !ok {
+		return nil, fmt.Errorf("expected store path but got %s", value.TypeName())
+	}
+	return result, nil
+}
+
 func makeSymlink(result string) error {
 	// Check if the file or directory exists
 	info, err := os.Lstat("result")
@@ -36,83 +210,145 @@ func makeSymlink(result string) error {
 		}
 	}
 
-	return os.Symlink(result, "result")
+	if err := os.Symlink(result, "result"); err != nil {
+		return err
+	}
+	return store.AddResultRoot("result")
 }
 
-func main() {
-	printast := false
-	printsource := false
-	printhash := false
-	makeresult := false
-	i := 0
-argloop:
-	for i = 1; i < len(os.Args); i++ {
-		switch os.Args[i] {
-		case "--help", "-h":
-			fmt.Print(helpmsg)
-			os.Exit(0)
-		case "--ast", "-t":
-			printast = true
-		case "--hash", "-H":
-			printhash = true
-		case "--source", "-s":
-			printsource = true
-		case "--result":
-			makeresult = true
-		default:
-			if os.Args[i][0] == '-' {
-				fmt.Fprintf(os.Stderr, "error: unknown option '%s'\n%s", os.Args[i], helpmsg)
-				os.Exit(1)
-			}
-			break argloop
-		}
+func buildCommand(args []string) error {
+	flags := newFlags("build")
+	options := evalFlags(flags)
+	makeresult := flags.Bool("result", false, "")
+	flags.BoolVar(makeresult, "r", false, "")
+	args, err := parseFlags(flags, args, 1, 2)
+	if err != nil {
+		return err
 	}
-	if i != len(os.Args)-1 {
-		fmt.Fprint(os.Stderr, helpmsg)
-		os.Exit(1)
+
+	value, err := evaluatePath(options, args)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(value.Path); err != nil {
+		return fmt.Errorf("unable to stat result: %v", err)
+	}
+	fmt.Println(value.Path)
+
+	if *makeresult {
+		return makeSymlink(value.Path)
 	}
+	return nil
+}
 
-	filename := os.Args[i]
-	eval, err := parser.ParseFile(filename)
+func evalCommand(args []string) error {
+	flags := newFlags("eval")
+	options := evalFlags(flags)
+	printast := flags.Bool("ast", false, "")
+	flags.BoolVar(printast, "t", false, "")
+	printsource := flags.Bool("source", false, "")
+	flags.BoolVar(printsource, "s", false, "")
+	args, err := parseFlags(flags, args, 1, 2)
 	if err != nil {
-		errors.PrintTrace(os.Stdout, err)
-		os.Exit(1)
+		return err
 	}
 
-	if printhash {
-		fmt.Println(ast.NodeHash(eval))
-		os.Exit(0)
+	if *printast {
+		node, err := parser.ParseFile(args[0])
+		if err != nil {
+			return err
+		}
+		ast.PrintTree(os.Stdout, node, 0)
+		return nil
 	}
 
-	if printast {
-		ast.PrintTree(os.Stdout, eval, 0)
-		os.Exit(0)
+	value, err := evaluateFile(options, args)
+	if err != nil {
+		return err
+	}
+	/* strings are printed as is, so scripts can be inspected */
+	str, ok := value.(*types.StringValue)
+	if !ok {
+		repr, err := types.Repr(value)
+		if err != nil {
+			return err
+		}
+		fmt.Println(repr)
+		return nil
+	}
+	fmt.Println(str.Content)
+
+	if *printsource {
+		for ss := range str.FlatSources() {
+			fmt.Printf("%d-%d: %s\n", ss.Start, ss.Start+ss.Len, ss.Value.GetNode().Name())
+		}
+	}
+	return nil
+}
+
+/* hashCommand prints the hash of the store-path a recipe evaluates to, which is accepted by remove */
+func hashCommand(args []string) error {
+	flags := newFlags("hash")
+	options := evalFlags(flags)
+	args, err := parseFlags(flags, args, 1, 2)
+	if err != nil {
+		return err
 	}
 
-	ctx := types.Scope{}
-	value, err := ctx.Evaluate(eval)
+	value, err := evaluatePath(options, args)
 	if err != nil {
-		errors.PrintTrace(os.Stdout, err)
+		return err
+	}
+	fmt.Println(store.HashPart(value.Path))
+	return nil
+}
+
+func helpCommand(args []string) error {
+	if len(args) == 0 {
+		fmt.Print(logo)
+		fmt.Print(helpmsg)
+		return nil
+	}
+	if _, ok := commands[args[0]]; !ok {
+		return &usageError{"", fmt.Sprintf("unknown command '%s'", args[0])}
+	}
+	fmt.Print(commandHelp(args[0]))
+	return nil
+}
+
+func main() {
+	sandbox.Init()
+	util.HandleInterrupt()
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, helpmsg)
 		os.Exit(1)
 	}
 
-	fmt.Println(value.Content)
+	name := os.Args[1]
+	switch name {
+	case "--help", "-h":
+		name = "help"
+	}
 
-	if printsource {
-		for ss := range value.FlatSources() {
-			fmt.Printf("%d-%d: %s\n", ss.Start, ss.Start+ss.Len, ss.Value.Node.Name())
-		}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "error: unknown command '%s'\n%s", name, helpmsg)
+		os.Exit(1)
 	}
 
-	if makeresult {
-		fmt.Println(value.Content)
-		if makeresult {
-			path := value.Content
-			if _, err := os.Lstat(path); err != nil {
-				fmt.Fprintf(os.Stderr, "error: unable to stat result: %v\n", err)
-				os.Exit(1)
-			}
-			makeSymlink(path)
+	err := cmd(os.Args[2:])
+	switch err := err.(type) {
+	case nil:
+	case *usageError:
+		fmt.Fprintf(os.Stderr, "error: %s\n%s", err.message, commandHelp(err.command))
+		os.Exit(1)
+	default:
+		if err == flag.ErrHelp {
+			fmt.Print(commandHelp(name))
+			return
 		}
+		errors.PrintTrace(os.Stderr, err)
+		os.Exit(1)
 	}
 }
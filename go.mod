@@ -2,4 +2,7 @@ module friedelschoen.io/paccat
 
 go 1.23.2
 
-require github.com/agnivade/levenshtein v1.2.0
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/agnivade/levenshtein v1.2.0
+)
@@ -0,0 +1,23 @@
+package ast
+
+import (
+	"friedelschoen.io/paccat/internal/errors"
+)
+
+type AssertNode struct {
+	Pos       errors.Position
+	Condition Node
+	Target    Node
+}
+
+func (this *AssertNode) Name() string {
+	return "assert"
+}
+
+func (this *AssertNode) GetPosition() errors.Position {
+	return this.Pos
+}
+
+func (this *AssertNode) GetChildren() []Node {
+	return []Node{this.Condition, this.Target}
+}
@@ -5,11 +5,15 @@ import (
 )
 
 type DictNode struct {
-	Pos   errors.Position
-	Items LiteralMap
+	Pos       errors.Position
+	Items     LiteralMap
+	Recursive bool /* items can refer to each other */
 }
 
 func (this *DictNode) Name() string {
+	if this.Recursive {
+		return "rec dict"
+	}
 	return "dict"
 }
 
@@ -0,0 +1,22 @@
+package ast
+
+import (
+	"friedelschoen.io/paccat/internal/errors"
+)
+
+type FetchNode struct {
+	Pos     errors.Position
+	Options Node
+}
+
+func (this *FetchNode) Name() string {
+	return "fetchurl"
+}
+
+func (this *FetchNode) GetPosition() errors.Position {
+	return this.Pos
+}
+
+func (this *FetchNode) GetChildren() []Node {
+	return []Node{this.Options}
+}
@@ -0,0 +1,24 @@
+package ast
+
+import (
+	"friedelschoen.io/paccat/internal/errors"
+)
+
+type IfNode struct {
+	Pos       errors.Position
+	Condition Node
+	Then      Node
+	Else      Node
+}
+
+func (this *IfNode) Name() string {
+	return "if"
+}
+
+func (this *IfNode) GetPosition() errors.Position {
+	return this.Pos
+}
+
+func (this *IfNode) GetChildren() []Node {
+	return []Node{this.Condition, this.Then, this.Else}
+}
@@ -0,0 +1,23 @@
+package ast
+
+import (
+	"friedelschoen.io/paccat/internal/errors"
+)
+
+type LetNode struct {
+	Pos      errors.Position
+	Bindings LiteralMap
+	Target   Node
+}
+
+func (this *LetNode) Name() string {
+	return "let"
+}
+
+func (this *LetNode) GetPosition() errors.Position {
+	return this.Pos
+}
+
+func (this *LetNode) GetChildren() []Node {
+	return []Node{this.Bindings, this.Target}
+}
@@ -2,7 +2,6 @@ package ast
 
 import (
 	"math"
-	"slices"
 
 	"friedelschoen.io/paccat/internal/errors"
 )
@@ -24,13 +23,23 @@ func (this *LiteralNode) GetChildren() []Node {
 	return []Node{}
 }
 
-type LiteralMap map[string]LiteralMapPair
+/* LiteralMap is a list of key-value pairs in the order of the source */
+type LiteralMap []LiteralMapPair
 
 type LiteralMapPair struct {
 	Key   *LiteralNode
 	Value Node
 }
 
+func (this LiteralMap) Get(key string) (LiteralMapPair, bool) {
+	for _, pair := range this {
+		if pair.Key.Content == key {
+			return pair, true
+		}
+	}
+	return LiteralMapPair{}, false
+}
+
 func (this LiteralMap) Name() string {
 	return "literalmap"
 }
@@ -49,6 +58,9 @@ func (this LiteralMap) GetPosition() errors.Position {
 		if pair.Key.Pos.End > pos.End {
 			pos.End = pair.Key.Pos.End
 		}
+		if pair.Value == nil {
+			continue
+		}
 		if pair.Value.GetPosition().Start < pos.Start {
 			pos.Start = pair.Value.GetPosition().Start
 		}
@@ -60,21 +72,12 @@ func (this LiteralMap) GetPosition() errors.Position {
 }
 
 func (this LiteralMap) GetChildren() []Node {
-	keys := make([]string, len(this))
-	i := 0
-	for key := range this {
-		keys[i] = key
-		i++
-	}
-	slices.Sort(keys)
-
-	res := make([]Node, 2*len(this))
-	i = 0
-	for _, key := range keys {
-		res[i] = this[key].Key
-		i++
-		res[i] = this[key].Value
-		i++
+	res := make([]Node, 0, 2*len(this))
+	for _, pair := range this {
+		res = append(res, pair.Key)
+		if pair.Value != nil {
+			res = append(res, pair.Value)
+		}
 	}
 	return res
 }
@@ -0,0 +1,23 @@
+package ast
+
+import (
+	"friedelschoen.io/paccat/internal/errors"
+)
+
+/* LookupNode is a path which is searched in the search-path, written as <name/file> */
+type LookupNode struct {
+	Pos     errors.Position
+	Content *LiteralNode
+}
+
+func (this *LookupNode) Name() string {
+	return "lookup"
+}
+
+func (this *LookupNode) GetPosition() errors.Position {
+	return this.Pos
+}
+
+func (this *LookupNode) GetChildren() []Node {
+	return []Node{this.Content}
+}
@@ -42,9 +42,8 @@ func PrintTree(w io.Writer, node Node, level int) {
 	fmt.Fprintf(w, "`%s` at %d-%d: %s\n", string(name), pos.Start, pos.End, NodeHash(node))
 
 	for _, child := range node.GetChildren() {
-		for level > 0 {
+		for range level {
 			w.Write(indent)
-			level--
 		}
 		w.Write([]byte("- "))
 		PrintTree(w, child, level+1)
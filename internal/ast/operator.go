@@ -0,0 +1,42 @@
+package ast
+
+import (
+	"friedelschoen.io/paccat/internal/errors"
+)
+
+type BinaryNode struct {
+	Pos      errors.Position
+	Operator string
+	Left     Node
+	Right    Node
+}
+
+func (this *BinaryNode) Name() string {
+	return "binary " + this.Operator
+}
+
+func (this *BinaryNode) GetPosition() errors.Position {
+	return this.Pos
+}
+
+func (this *BinaryNode) GetChildren() []Node {
+	return []Node{this.Left, this.Right}
+}
+
+type UnaryNode struct {
+	Pos      errors.Position
+	Operator string
+	Target   Node
+}
+
+func (this *UnaryNode) Name() string {
+	return "unary " + this.Operator
+}
+
+func (this *UnaryNode) GetPosition() errors.Position {
+	return this.Pos
+}
+
+func (this *UnaryNode) GetChildren() []Node {
+	return []Node{this.Target}
+}
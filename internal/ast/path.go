@@ -0,0 +1,22 @@
+package ast
+
+import (
+	"friedelschoen.io/paccat/internal/errors"
+)
+
+type PathNode struct {
+	Pos     errors.Position
+	Content *LiteralNode
+}
+
+func (this *PathNode) Name() string {
+	return "path"
+}
+
+func (this *PathNode) GetPosition() errors.Position {
+	return this.Pos
+}
+
+func (this *PathNode) GetChildren() []Node {
+	return []Node{this.Content}
+}
@@ -6,12 +6,27 @@ import (
 	"strings"
 )
 
+type traceFrame struct {
+	pos     Position
+	message string
+}
+
 func PrintTrace(writer io.Writer, current error) {
+	/* frames of a recursion repeat, these are printed once */
+	printed := map[traceFrame]bool{}
+	repeated := 0
 	for current != nil {
 		err, ok := current.(Positioned)
 		if !ok {
 			fmt.Fprintf(writer, "??: %v\n", current)
+		} else if frame := (traceFrame{err.GetPosition(), current.Error()}); printed[frame] {
+			repeated++
 		} else {
+			printed[frame] = true
+			if repeated > 0 {
+				fmt.Fprintf(writer, "... %d repeated frames omitted\n", repeated)
+				repeated = 0
+			}
 			pos := err.GetPosition()
 
 			endOffset := 0
@@ -78,4 +93,7 @@ func PrintTrace(writer io.Writer, current error) {
 		}
 		current = prev.Previous()
 	}
+	if repeated > 0 {
+		fmt.Fprintf(writer, "... %d repeated frames omitted\n", repeated)
+	}
 }
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 )
 
 type PackageDatabase struct {
@@ -40,14 +41,18 @@ func (db *PackageDatabase) Install(pkgname, pathname string) error {
 			return err
 		}
 
+		if relPath == "." {
+			return nil
+		}
+
 		targetPath := path.Join(db.Prefix, relPath)
 		if info.IsDir() {
-			csvWriter.Write([]string{pkgname, "link", targetPath})
-			if err := os.MkdirAll(targetPath, info.Mode()); err != nil {
+			csvWriter.Write([]string{pkgname, "dir", targetPath})
+			if err := os.MkdirAll(targetPath, info.Mode()|0200); err != nil {
 				return err
 			}
 		} else {
-			csvWriter.Write([]string{pkgname, "dir", targetPath})
+			csvWriter.Write([]string{pkgname, "link", targetPath})
 			if err := os.Symlink(currentPath, targetPath); err != nil {
 				return err
 			}
@@ -87,7 +92,7 @@ func (db *PackageDatabase) Remove(pkgname string) error {
 	defer oldfile.Close()
 
 	newpath := path.Join(db.Prefix, "paccat.index.new")
-	newfile, err := os.OpenFile(newpath, os.O_WRONLY|os.O_CREATE, 0644)
+	newfile, err := os.OpenFile(newpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		return err
 	}
@@ -98,25 +103,66 @@ func (db *PackageDatabase) Remove(pkgname string) error {
 	writer := csv.NewWriter(newfile)
 	defer writer.Flush()
 
+	var removing [][]string
 	for {
 		record, err := reader.Read()
 		if err != nil {
 			break
 		}
 
-		name := record[0]
-		if name != pkgname {
+		if record[0] != pkgname {
 			writer.Write(record)
 			continue
 		}
-		fmt.Printf("removing %s\n", name)
+		removing = append(removing, record)
+	}
 
-		target := record[2]
+	if len(removing) == 0 {
+		return fmt.Errorf("package %s is not installed", pkgname)
+	}
+	fmt.Printf("removing %s\n", pkgname)
+
+	/* remove in reverse order, so directories are emptied before they are removed */
+	for i := len(removing) - 1; i >= 0; i-- {
+		kind, target := removing[i][1], removing[i][2]
 
+		if kind == "dir" {
+			if empty, err := isEmpty(target); err != nil || !empty {
+				continue
+			}
+		}
 		if err = os.Remove(target); err != nil {
-			fmt.Fprintf(os.Stderr, "unable to remove %s %s: %v\n", record[1], target, err)
+			fmt.Fprintf(os.Stderr, "unable to remove %s %s: %v\n", kind, target, err)
 		}
 	}
 
 	return nil
 }
+
+// packages returns the names of all installed packages.
+func (db *PackageDatabase) Packages() ([]string, error) {
+	file, err := os.Open(path.Join(db.Prefix, "paccat.index"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var names []string
+	reader := csv.NewReader(file)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(names, record[0]) {
+			names = append(names, record[0])
+		}
+	}
+	return names, nil
+}
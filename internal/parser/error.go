@@ -9,8 +9,18 @@ import (
 )
 
 type parseError struct {
-	got    Token
-	expect []string /* expected ... */
+	got      Token
+	expect   []string /* expected ... */
+	message  string   /* replaces the expected-message, these errors are not recovered from */
+	previous error
+}
+
+func (this *parseError) fatal() bool {
+	return this.message != ""
+}
+
+func (this *parseError) Previous() error {
+	return this.previous
 }
 
 func unique[T comparable](slc []T) []T {
@@ -29,6 +39,9 @@ func unique[T comparable](slc []T) []T {
 }
 
 func (this *parseError) Error() string {
+	if this.message != "" {
+		return this.message
+	}
 	this.expect = unique(this.expect)
 	slices.Sort(this.expect)
 
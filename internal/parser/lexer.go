@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 
 	"friedelschoen.io/paccat/internal/errors"
 )
@@ -15,6 +16,8 @@ type tokenDefine struct {
 	name        string
 	stateChange stateFunc
 	expr        testFunc
+	valueStart  bool /* only matches where a value can start, not directly after a value */
+	afterValue  bool /* only matches directly after a value on the same line */
 }
 
 type Token struct {
@@ -41,6 +44,25 @@ type Tokenizer struct {
 	Token Token
 }
 
+/* endsValue reports whether tok can be the last token of a value */
+func endsValue(tok Token) bool {
+	switch tok.Name {
+	case "ident", "number", "path", "lookup", "string-end", "multi-end":
+		return true
+	case "symbol":
+		return tok.Content == ")" || tok.Content == "]" || tok.Content == "}"
+	}
+	return false
+}
+
+/* followsValue reports whether the current position is on the same line as the end of a value, `//` there is the merge-operator instead of a comment */
+func (this *Tokenizer) followsValue() bool {
+	if !endsValue(this.Token) || this.Token.Pos.End > this.Pos {
+		return false
+	}
+	return !strings.ContainsAny(this.File.Content[this.Token.Pos.End:this.Pos], "\n\r")
+}
+
 func (this *Tokenizer) Next() bool {
 	if len(this.current) == 0 {
 		if len(this.current) == 0 {
@@ -84,7 +106,7 @@ func (this *Tokenizer) Next() bool {
 	}
 
 	for _, tok := range tokens {
-		if tok.state != this.current[0] {
+		if tok.state != this.current[0] || (tok.valueStart && endsValue(this.Token)) || (tok.afterValue && !this.followsValue()) {
 			continue
 		}
 
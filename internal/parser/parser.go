@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"fmt"
+	"slices"
 	"strings"
 
 	"friedelschoen.io/paccat/internal/ast"
@@ -25,6 +27,9 @@ func (this *parseState) choice(choices ...func() (ast.Node, *parseError)) (ast.N
 		if err == nil {
 			return res, nil
 		}
+		if err.fatal() {
+			return nil, err
+		}
 		this.Load(lexsave)
 
 		if err.got.Pos.Start > got.Pos.Start {
@@ -34,7 +39,7 @@ func (this *parseState) choice(choices ...func() (ast.Node, *parseError)) (ast.N
 			expect = append(expect, err.expect...)
 		}
 	}
-	return nil, &parseError{got, expect}
+	return nil, &parseError{got: got, expect: expect}
 }
 
 func (this *parseState) expectToken(name string) (Token, *parseError) {
@@ -43,7 +48,7 @@ func (this *parseState) expectToken(name string) (Token, *parseError) {
 		this.Next()
 		return current, nil
 	}
-	return Token{}, &parseError{this.Token, []string{name}}
+	return Token{}, &parseError{got: this.Token, expect: []string{name}}
 }
 
 func (this *parseState) expectTokenContent(content string) (Token, *parseError) {
@@ -52,7 +57,7 @@ func (this *parseState) expectTokenContent(content string) (Token, *parseError)
 		this.Next()
 		return current, nil
 	}
-	return Token{}, &parseError{this.Token, []string{"`" + content + "`"}}
+	return Token{}, &parseError{got: this.Token, expect: []string{"`" + content + "`"}}
 }
 
 func (this *parseState) asLiteral(tok Token) *ast.LiteralNode {
@@ -62,6 +67,22 @@ func (this *parseState) asLiteral(tok Token) *ast.LiteralNode {
 	}
 }
 
+/* addPair appends a pair to items, keys may only occur once */
+func (this *parseState) addPair(items *ast.LiteralMap, ident Token, value ast.Node) *parseError {
+	if prev, ok := items.Get(ident.Content); ok {
+		return &parseError{
+			got:      ident,
+			message:  fmt.Sprintf("duplicate key `%s`", ident.Content),
+			previous: errors.NewRecipeError(prev.Key.GetPosition(), fmt.Sprintf("`%s` is first defined here", ident.Content)),
+		}
+	}
+	*items = append(*items, ast.LiteralMapPair{
+		Key:   this.asLiteral(ident),
+		Value: value,
+	})
+	return nil
+}
+
 func (this *parseState) parseLambda() (ast.Node, *parseError) {
 	begin, err := this.expectTokenContent("(")
 	if err != nil {
@@ -88,9 +109,8 @@ tokenLoop:
 				return nil, err
 			}
 		}
-		args[ident.Content] = ast.LiteralMapPair{
-			Key:   this.asLiteral(ident),
-			Value: def,
+		if err := this.addPair(&args, ident, def); err != nil {
+			return nil, err
 		}
 	}
 
@@ -142,9 +162,8 @@ tokenLoop:
 		if err != nil {
 			return nil, err
 		}
-		items[ident.Content] = ast.LiteralMapPair{
-			Key:   this.asLiteral(ident),
-			Value: value,
+		if err := this.addPair(&items, ident, value); err != nil {
+			return nil, err
 		}
 	}
 
@@ -159,6 +178,58 @@ tokenLoop:
 	}, nil
 }
 
+func (this *parseState) parseRecDict() (ast.Node, *parseError) {
+	begin, err := this.expectTokenContent("rec")
+	if err != nil {
+		return nil, err
+	}
+	node, err := this.parseDict()
+	if err != nil {
+		return nil, err
+	}
+	dict := node.(*ast.DictNode)
+	dict.Pos = stretch(begin, dict)
+	dict.Recursive = true
+	return dict, nil
+}
+
+func (this *parseState) parseLet() (ast.Node, *parseError) {
+	begin, err := this.expectTokenContent("let")
+	if err != nil {
+		return nil, err
+	}
+	bindings := ast.LiteralMap{}
+	for this.Token.Name == "ident" {
+		ident := this.Token
+		this.Next()
+		if _, err := this.expectTokenContent("="); err != nil {
+			return nil, err
+		}
+		value, err := this.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := this.expectTokenContent(";"); err != nil {
+			return nil, err
+		}
+		if err := this.addPair(&bindings, ident, value); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := this.expectTokenContent("in"); err != nil {
+		return nil, err
+	}
+	target, err := this.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.LetNode{
+		Pos:      stretch(begin, target),
+		Bindings: bindings,
+		Target:   target,
+	}, nil
+}
+
 func (this *parseState) parseList() (ast.Node, *parseError) {
 	begin, err := this.expectTokenContent("[")
 	if err != nil {
@@ -175,6 +246,9 @@ tokenLoop:
 		}
 		value, err := this.parseValue()
 		if err != nil {
+			if err.fatal() {
+				return nil, err
+			}
 			break tokenLoop
 		}
 		items = append(items, value)
@@ -245,6 +319,21 @@ func (this *parseState) parseOutput() (ast.Node, *parseError) {
 	}, nil
 }
 
+func (this *parseState) parseFetch() (ast.Node, *parseError) {
+	begin, err := this.expectTokenContent("fetchurl")
+	if err != nil {
+		return nil, err
+	}
+	options, err := this.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.FetchNode{
+		Pos:     stretch(begin, options),
+		Options: options,
+	}, nil
+}
+
 func (this *parseState) parseImport() (ast.Node, *parseError) {
 	begin, err := this.expectTokenContent("import")
 	if err != nil {
@@ -275,10 +364,64 @@ func (this *parseState) parsePanic() (ast.Node, *parseError) {
 	}, nil
 }
 
+func (this *parseState) parseIf() (ast.Node, *parseError) {
+	begin, err := this.expectTokenContent("if")
+	if err != nil {
+		return nil, err
+	}
+	condition, err := this.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := this.expectTokenContent("then"); err != nil {
+		return nil, err
+	}
+	then, err := this.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := this.expectTokenContent("else"); err != nil {
+		return nil, err
+	}
+	otherwise, err := this.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.IfNode{
+		Pos:       stretch(begin, otherwise),
+		Condition: condition,
+		Then:      then,
+		Else:      otherwise,
+	}, nil
+}
+
+func (this *parseState) parseAssert() (ast.Node, *parseError) {
+	begin, err := this.expectTokenContent("assert")
+	if err != nil {
+		return nil, err
+	}
+	condition, err := this.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := this.expectTokenContent(";"); err != nil {
+		return nil, err
+	}
+	target, err := this.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.AssertNode{
+		Pos:       stretch(begin, target),
+		Condition: condition,
+		Target:    target,
+	}, nil
+}
+
 func (this *parseState) parseString() (ast.Node, *parseError) {
 	begin := this.Token
 	if begin.Content != "\"" && begin.Content != "''" {
-		return nil, &parseError{this.Token, []string{"`''`", "`\"`"}}
+		return nil, &parseError{got: this.Token, expect: []string{"`''`", "`\"`"}}
 	}
 	this.Next()
 
@@ -353,9 +496,23 @@ func (this *parseState) parsePath() (ast.Node, *parseError) {
 	if err != nil {
 		return nil, err
 	}
-	return &ast.LiteralNode{
+	return &ast.PathNode{
 		Pos:     val.GetPosition(),
-		Content: val.Content,
+		Content: this.asLiteral(val),
+	}, nil
+}
+
+func (this *parseState) parseLookup() (ast.Node, *parseError) {
+	val, err := this.expectToken("lookup")
+	if err != nil {
+		return nil, err
+	}
+	return &ast.LookupNode{
+		Pos: val.GetPosition(),
+		Content: &ast.LiteralNode{
+			Pos:     val.GetPosition(),
+			Content: strings.Trim(val.Content, "<>"),
+		},
 	}, nil
 }
 
@@ -374,18 +531,93 @@ func (this *parseState) parseAttrify() (ast.Node, *parseError) {
 	}, nil
 }
 
+/* binaryOperators are ordered by precedence, from loosest to tightest binding */
+var binaryOperators = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"//"},
+	{"+", "-"},
+	{"*", "/"},
+	{"++"},
+}
+
 func (this *parseState) parseValue() (ast.Node, *parseError) {
+	value, err := this.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	/* a value is never followed by a path, `a/b` is lexed as `a` and the path `/b` */
+	if this.Token.Name == "path" && strings.HasPrefix(this.Token.Content, "/") {
+		return nil, &parseError{
+			got:     this.Token,
+			message: fmt.Sprintf("unexpected path `%s`, division requires a space after `/`", this.Token.Content),
+		}
+	}
+	return value, nil
+}
+
+func (this *parseState) parseBinary(level int) (ast.Node, *parseError) {
+	if level == len(binaryOperators) {
+		return this.parseUnary()
+	}
+	left, err := this.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for this.Token.Name == "operator" && slices.Contains(binaryOperators[level], this.Token.Content) {
+		operator := this.Token.Content
+		this.Next()
+		right, err := this.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &ast.BinaryNode{
+			Pos:      stretch(left, right),
+			Operator: operator,
+			Left:     left,
+			Right:    right,
+		}
+	}
+	return left, nil
+}
+
+func (this *parseState) parseUnary() (ast.Node, *parseError) {
+	begin := this.Token
+	if begin.Name != "operator" || (begin.Content != "!" && begin.Content != "-") {
+		return this.parsePostfix()
+	}
+	this.Next()
+	target, err := this.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return &ast.UnaryNode{
+		Pos:      stretch(begin, target),
+		Operator: begin.Content,
+		Target:   target,
+	}, nil
+}
+
+func (this *parseState) parsePostfix() (ast.Node, *parseError) {
 	val, err := this.choice(
 		this.parseString,
 		this.parseNumber,
 		this.parsePath,
+		this.parseLookup,
 		this.parseLambda,
 		this.parseSurrounded,
 		this.parseList,
 		this.parseDict,
+		this.parseRecDict,
+		this.parseLet,
 		this.parseOutput,
+		this.parseFetch,
 		this.parseImport,
 		this.parsePanic,
+		this.parseIf,
+		this.parseAssert,
 		this.parseAttrify,
 		this.parseReference,
 	)
@@ -432,6 +664,8 @@ tokenLoop:
 					if err != nil {
 						break argLoop
 					}
+				} else if this.Token.Content == ")" {
+					break argLoop
 				}
 				ident, err := this.expectToken("ident")
 				if err != nil {
@@ -445,9 +679,8 @@ tokenLoop:
 				if err != nil {
 					return nil, err
 				}
-				args[ident.Content] = ast.LiteralMapPair{
-					Key:   this.asLiteral(ident),
-					Value: value,
+				if err := this.addPair(&args, ident, value); err != nil {
+					return nil, err
 				}
 			}
 			end, err := this.expectTokenContent(")")
@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"friedelschoen.io/paccat/internal/ast"
+	"friedelschoen.io/paccat/internal/errors"
+)
+
+func TestParseComments(t *testing.T) {
+	tests := []string{
+		"// line comment\n1",
+		"{ a = 1, // trailing comment\n b = 2 }",
+		"{\n  a = 1\n  // comment on its own line\n}",
+		"/* block\n comment */ 1",
+		"[ ./src, /usr/share, 6 / 2 ]",
+	}
+	for _, source := range tests {
+		if _, err := Parse("test.pcr", source); err != nil {
+			t.Errorf("unable to parse `%s`: %v", source, err)
+		}
+	}
+}
+
+func TestParseMerge(t *testing.T) {
+	tests := []string{
+		"{ a = 1 } // { b = 2 }",
+		"a//b",
+		"f(x = 1) // { b = 2 } /* merged */",
+		"(a) // b // c",
+	}
+	for _, source := range tests {
+		node, err := Parse("test.pcr", source)
+		if err != nil {
+			t.Errorf("unable to parse `%s`: %v", source, err)
+			continue
+		}
+		if binary, ok := node.(*ast.BinaryNode); !ok || binary.Operator != "//" {
+			t.Errorf("`%s` parsed as %s", source, node.Name())
+		}
+	}
+}
+
+func TestParseDivision(t *testing.T) {
+	tests := []struct {
+		source string
+		at     int
+	}{
+		{"a/b", 1},
+		{"(6/2)", 2},
+		{"[ 1, x/2 ]", 6},
+	}
+	for _, test := range tests {
+		_, err := Parse("test.pcr", test.source)
+		if err == nil {
+			t.Errorf("`%s` parsed without error", test.source)
+			continue
+		}
+		if !strings.Contains(err.Error(), "division requires a space") {
+			t.Errorf("`%s`: error = %q", test.source, err.Error())
+		}
+		if positioned, ok := err.(errors.Positioned); !ok || positioned.GetPosition().Start != test.at {
+			t.Errorf("`%s`: error is not reported at offset %d", test.source, test.at)
+		}
+	}
+}
+
+func TestParseLookup(t *testing.T) {
+	tests := []struct {
+		source string
+		lookup bool /* whether source is a lookup */
+	}{
+		{"<pkgs/dwm.pcr>", true},
+		{"if true then <pkgs> else null", false},
+		{"a <b> 0", false},
+		{"(a) <b> 0", false},
+		{"a<b", false},
+	}
+	for _, test := range tests {
+		node, err := Parse("test.pcr", test.source)
+		if err != nil {
+			t.Errorf("unable to parse `%s`: %v", test.source, err)
+			continue
+		}
+		if _, ok := node.(*ast.LookupNode); ok != test.lookup {
+			t.Errorf("`%s` parsed as %s", test.source, node.Name())
+		}
+	}
+}
@@ -45,16 +45,19 @@ func literalTest(literals ...string) func(string) int {
 
 var tokens = []tokenDefine{
 	{state: "root", name: "interp-end", stateChange: statePop(), expr: literalTest("}}")},
-	{state: "root", name: "path", stateChange: nil, expr: regexTest("\\.{0,2}/[a-zA-Z0-9._-]*")},
+	{state: "root", name: "", stateChange: nil, expr: regexTest("/\\*(\\s|.)*?\\*/")},
+	{state: "root", name: "operator", stateChange: nil, expr: literalTest("//"), afterValue: true},
+	{state: "root", name: "", stateChange: nil, expr: regexTest("//[^\\n\\r]*")},
+	{state: "root", name: "path", stateChange: nil, expr: regexTest("\\.{1,2}/([a-zA-Z0-9._-]+/?)*|/[a-zA-Z0-9._-]+(/[a-zA-Z0-9._-]+)*/?")},
 	{state: "root", name: "arrow", stateChange: nil, expr: literalTest("->")},
+	{state: "root", name: "lookup", stateChange: nil, expr: regexTest("<[a-zA-Z0-9._-]+(/[a-zA-Z0-9._-]+)*>"), valueStart: true},
+	{state: "root", name: "operator", stateChange: nil, expr: literalTest("++", "==", "!=", "<=", ">=", "&&", "||", "+", "-", "*", "/", "<", ">", "!")},
 	{state: "root", name: "symbol", stateChange: nil, expr: regexTest("[#(){}[\\].=,\\\\;]")},
 	{state: "root", name: "number", stateChange: nil, expr: regexTest("[0-9]+")},
 	{state: "root", name: "multiline-begin", stateChange: statePush("multi"), expr: literalTest("''")},
 	{state: "root", name: "string-begin", stateChange: statePush("string"), expr: literalTest("\"")},
-	{state: "root", name: "keyword", stateChange: nil, expr: literalTest("panic", "output", "import")},
+	{state: "root", name: "keyword", stateChange: nil, expr: regexTest("(panic|output|import|fetchurl|if|then|else|assert|let|in|rec)\\b")},
 	{state: "root", name: "ident", stateChange: nil, expr: regexTest("[a-zA-Z0-9_]+")},
-	{state: "root", name: "", stateChange: nil, expr: regexTest("//[^\\n\\r]*")},
-	{state: "root", name: "", stateChange: nil, expr: regexTest("/\\*(\\s|.)*\\*/")},
 	{state: "root", name: "", stateChange: nil, expr: regexTest("[ \\t\\n\\r]")},
 	{state: "string", name: "interp-begin", stateChange: statePush("root"), expr: literalTest("{{")},
 	{state: "string", name: "string-end", stateChange: statePop(), expr: literalTest("\"")},
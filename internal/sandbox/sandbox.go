@@ -0,0 +1,41 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const (
+	initName = "paccat-sandbox" /* argv[0] of the re-executed sandbox-init */
+)
+
+type Bind struct {
+	Source   string /* path on the host */
+	Target   string /* path inside the sandbox */
+	Writable bool
+}
+
+type Config struct {
+	Root    string /* empty directory which becomes the root of the sandbox */
+	Binds   []Bind
+	Dir     string /* working directory inside the sandbox */
+	Network bool
+}
+
+/* Init runs the sandbox-init if this process was started by Command, it never returns in that case */
+func Init() {
+	if len(os.Args) < 3 || os.Args[0] != initName {
+		return
+	}
+
+	var cfg Config
+	if err := json.Unmarshal([]byte(os.Args[1]), &cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: invalid configuration: %v\n", err)
+		os.Exit(127)
+	}
+	if err := runInit(&cfg, os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		os.Exit(127)
+	}
+}
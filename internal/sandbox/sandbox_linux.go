@@ -0,0 +1,168 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+var devices = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom"}
+
+/* Command prepares a command which runs name inside user-, mount-, pid- and (without Network) network-namespaces */
+func Command(cfg *Config, name string, args ...string) (*exec.Cmd, error) {
+	content, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	flags := syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWUTS | syscall.CLONE_NEWIPC
+	if !cfg.Network {
+		flags |= syscall.CLONE_NEWNET
+	}
+
+	cmd := &exec.Cmd{
+		Path: "/proc/self/exe",
+		Args: append([]string{initName, string(content), name}, args...),
+		SysProcAttr: &syscall.SysProcAttr{
+			Cloneflags:  uintptr(flags),
+			UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+			GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+			Pdeathsig:   syscall.SIGKILL,
+		},
+	}
+	return cmd, nil
+}
+
+/* mountFlags translates the statfs-flags of a mount, which must be kept when remounting inside a user-namespace */
+func mountFlags(pathname string) (uintptr, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(pathname, &stat); err != nil {
+		return 0, err
+	}
+	const (
+		stNosuid     = 0x2
+		stNodev      = 0x4
+		stNoexec     = 0x8
+		stNoatime    = 0x400
+		stNodiratime = 0x800
+		stRelatime   = 0x1000
+	)
+	translate := map[int64]uintptr{
+		stNosuid:     syscall.MS_NOSUID,
+		stNodev:      syscall.MS_NODEV,
+		stNoexec:     syscall.MS_NOEXEC,
+		stNoatime:    syscall.MS_NOATIME,
+		stNodiratime: syscall.MS_NODIRATIME,
+		stRelatime:   syscall.MS_RELATIME,
+	}
+	var flags uintptr
+	for st, ms := range translate {
+		if int64(stat.Flags)&st != 0 {
+			flags |= ms
+		}
+	}
+	return flags, nil
+}
+
+func bindMount(source, target string, writable bool) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+	} else {
+		if err := os.MkdirAll(path.Dir(target), 0755); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		file.Close()
+	}
+
+	if err := syscall.Mount(source, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("unable to bind %s: %w", source, err)
+	}
+	if writable {
+		return nil
+	}
+	flags, err := mountFlags(target)
+	if err != nil {
+		return err
+	}
+	if err := syscall.Mount("", target, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY|flags, ""); err != nil {
+		return fmt.Errorf("unable to remount %s read-only: %w", source, err)
+	}
+	return nil
+}
+
+/* runInit is executed inside the namespaces, it builds the root-filesystem and executes the builder */
+func runInit(cfg *Config, argv []string) error {
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("unable to make / private: %w", err)
+	}
+	if err := syscall.Mount("tmpfs", cfg.Root, "tmpfs", 0, "mode=0755"); err != nil {
+		return fmt.Errorf("unable to mount root: %w", err)
+	}
+
+	for _, dir := range []string{"proc", "tmp"} {
+		if err := os.MkdirAll(path.Join(cfg.Root, dir), 0755); err != nil {
+			return err
+		}
+	}
+	if err := syscall.Mount("proc", path.Join(cfg.Root, "proc"), "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("unable to mount /proc: %w", err)
+	}
+	if err := syscall.Mount("tmpfs", path.Join(cfg.Root, "tmp"), "tmpfs", 0, "mode=1777"); err != nil {
+		return fmt.Errorf("unable to mount /tmp: %w", err)
+	}
+
+	/* parents have to be mounted before their children */
+	binds := slices.Clone(cfg.Binds)
+	slices.SortStableFunc(binds, func(left, right Bind) int {
+		return strings.Count(left.Target, "/") - strings.Count(right.Target, "/")
+	})
+	for _, bind := range binds {
+		if err := bindMount(bind.Source, path.Join(cfg.Root, bind.Target), bind.Writable); err != nil {
+			return err
+		}
+	}
+	for _, dev := range devices {
+		if err := bindMount(dev, path.Join(cfg.Root, dev), true); err != nil {
+			return err
+		}
+	}
+
+	/* pivot into the new root and detach the old one, which is stacked on top of it */
+	if err := syscall.Chdir(cfg.Root); err != nil {
+		return err
+	}
+	if err := syscall.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("unable to pivot root: %w", err)
+	}
+	if err := syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("unable to detach old root: %w", err)
+	}
+	if err := syscall.Chdir(cfg.Dir); err != nil {
+		return err
+	}
+	syscall.Sethostname([]byte("localhost"))
+
+	executable := argv[0]
+	if !strings.ContainsRune(executable, '/') {
+		var err error
+		if executable, err = exec.LookPath(executable); err != nil {
+			return err
+		}
+	}
+	return syscall.Exec(executable, argv, os.Environ())
+}
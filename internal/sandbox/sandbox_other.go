@@ -0,0 +1,16 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func Command(cfg *Config, name string, args ...string) (*exec.Cmd, error) {
+	return nil, errors.New("sandboxing is only supported on linux")
+}
+
+func runInit(cfg *Config, argv []string) error {
+	return errors.New("sandboxing is only supported on linux")
+}
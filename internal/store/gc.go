@@ -0,0 +1,139 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"friedelschoen.io/paccat/internal/util"
+)
+
+type GCOptions struct {
+	DryRun   bool  /* only report what would be deleted */
+	MaxFreed int64 /* stop after freeing this many bytes, 0 for no limit */
+}
+
+type GCResult struct {
+	Deleted []string
+	Freed   int64
+}
+
+/* ReadInfo returns the metadata of a valid store-path */
+func ReadInfo(pathname string) (*Info, error) {
+	content, err := os.ReadFile(metaPath(pathname))
+	if err != nil {
+		return nil, err
+	}
+	var info Info
+	if err := json.Unmarshal(content, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+/* Closure returns all store-paths reachable from roots, including the roots */
+func Closure(roots []string) ([]string, error) {
+	var closure []string
+	pending := slices.Clone(roots)
+	for len(pending) > 0 {
+		current := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if slices.Contains(closure, current) || !IsValid(current) {
+			continue
+		}
+		closure = append(closure, current)
+
+		info, err := ReadInfo(current)
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, info.References...)
+	}
+	slices.Sort(closure)
+	return closure, nil
+}
+
+func pathSize(pathname string) int64 {
+	var size int64
+	filepath.WalkDir(pathname, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+/* CollectGarbage deletes every store-path which is not reachable from a root, and the remains of interrupted builds */
+func CollectGarbage(options *GCOptions) (*GCResult, error) {
+	roots, err := FindRoots()
+	if err != nil {
+		return nil, err
+	}
+	alive, err := Closure(roots)
+	if err != nil {
+		return nil, err
+	}
+
+	storedir := util.GetCachedir()
+	entries, err := os.ReadDir(storedir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &GCResult{}
+	for _, entry := range entries {
+		if options.MaxFreed > 0 && result.Freed >= options.MaxFreed {
+			break
+		}
+		name := entry.Name()
+		pathname := path.Join(storedir, name)
+		if slices.Contains(alive, pathname) {
+			continue
+		}
+		/* metadata, locks and roots are cleaned up below, temporary build-paths are garbage */
+		if name == ".meta" || name == ".locks" || name == ".roots" {
+			continue
+		}
+
+		result.Deleted = append(result.Deleted, pathname)
+		result.Freed += pathSize(pathname)
+		if options.DryRun {
+			continue
+		}
+		if err := Invalidate(pathname); err != nil {
+			return result, fmt.Errorf("unable to delete %s: %w", pathname, err)
+		}
+	}
+
+	if !options.DryRun {
+		cleanDir(metaDir(), storedir)
+		cleanDir(lockDir(), storedir)
+	}
+	return result, nil
+}
+
+/* cleanDir removes the entries of dir whose store-path does not exist anymore */
+func cleanDir(dir, storedir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") && entry.Name() != ".gc" {
+			os.Remove(path.Join(dir, entry.Name()))
+			continue
+		}
+		if _, err := os.Lstat(path.Join(storedir, entry.Name())); os.IsNotExist(err) {
+			os.Remove(path.Join(dir, entry.Name()))
+		}
+	}
+}
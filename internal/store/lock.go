@@ -0,0 +1,58 @@
+package store
+
+import (
+	"os"
+	"path"
+	"syscall"
+
+	"friedelschoen.io/paccat/internal/util"
+)
+
+type Lock struct {
+	file *os.File
+}
+
+func lockDir() string {
+	dir := path.Join(util.GetCachedir(), ".locks")
+	os.MkdirAll(dir, 0755)
+	return dir
+}
+
+func lockFile(filename string, how int, waiting func()) (*Lock, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		if waiting != nil {
+			waiting()
+		}
+		err = syscall.Flock(int(file.Fd()), how)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &Lock{file}, nil
+}
+
+/* LockPath acquires the exclusive lock of a store-path, if another process holds it waiting is called before blocking */
+func LockPath(pathname string, waiting func()) (*Lock, error) {
+	return lockFile(path.Join(lockDir(), path.Base(pathname)), syscall.LOCK_EX, waiting)
+}
+
+/* LockGC acquires the garbage-collector lock, it is shared by evaluations and exclusive for the collector */
+func LockGC(exclusive bool, waiting func()) (*Lock, error) {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	return lockFile(path.Join(lockDir(), ".gc"), how, waiting)
+}
+
+func (this *Lock) Unlock() error {
+	defer this.file.Close()
+	return syscall.Flock(int(this.file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,86 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+/* ScanReferences returns the candidates whose hash occurs in a file or symlink of pathname */
+func ScanReferences(pathname string, candidates []string) ([]string, error) {
+	hashes := make([][]byte, len(candidates))
+	for i, candidate := range candidates {
+		hashes[i] = []byte(HashPart(candidate))
+	}
+	found := make([]bool, len(candidates))
+
+	scan := func(content []byte) {
+		for i, hash := range hashes {
+			if !found[i] && bytes.Contains(content, hash) {
+				found[i] = true
+			}
+		}
+	}
+
+	err := filepath.WalkDir(pathname, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(current)
+			if err != nil {
+				return err
+			}
+			scan([]byte(target))
+		case entry.Type().IsRegular():
+			return scanFile(current, scan)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var references []string
+	for i, candidate := range candidates {
+		if found[i] {
+			references = append(references, candidate)
+		}
+	}
+	slices.Sort(references)
+	return references, nil
+}
+
+/* scanFile passes the content of filename in overlapping chunks, so no hash is split between two chunks */
+func scanFile(filename string, scan func([]byte)) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	const chunkSize = 64 * 1024
+	buffer := make([]byte, HashLength+chunkSize)
+	kept := 0
+	for {
+		n, err := file.Read(buffer[kept:])
+		if n > 0 {
+			scan(buffer[:kept+n])
+			/* keep the tail, a hash may continue in the next chunk */
+			tail := min(kept+n, HashLength-1)
+			copy(buffer, buffer[kept+n-tail:kept+n])
+			kept = tail
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to scan %s: %w", filename, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"friedelschoen.io/paccat/internal/install"
+	"friedelschoen.io/paccat/internal/util"
+)
+
+/*
+ * The roots-directory contains symlinks to everything which must survive garbage-collection:
+ *   auto/      symlinks to ./result-links, these are removed when the result-link is gone
+ *   profiles/  symlinks to install-prefixes, the packages in their paccat.index are roots
+ * every other symlink in it is an explicit root.
+ */
+func rootDir() string {
+	return path.Join(util.GetCachedir(), ".roots")
+}
+
+func addRoot(kind, target string) error {
+	target, err := filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+	dir := path.Join(rootDir(), kind)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(target))
+	link := path.Join(dir, hex.EncodeToString(sum[:])[:HashLength])
+	os.Remove(link)
+	return os.Symlink(target, link)
+}
+
+/* AddResultRoot registers a symlink to a store-path, the store-path it points to survives garbage-collection */
+func AddResultRoot(link string) error {
+	return addRoot("auto", link)
+}
+
+/* AddProfileRoot registers an install-prefix, its installed packages survive garbage-collection */
+func AddProfileRoot(prefix string) error {
+	return addRoot("profiles", prefix)
+}
+
+/* TopLevel returns the store-path containing pathname, or "" if it is not inside the store */
+func TopLevel(pathname string) string {
+	storedir := util.GetCachedir()
+	if resolved, err := filepath.EvalSymlinks(storedir); err == nil {
+		storedir = resolved
+	}
+	rel, err := filepath.Rel(storedir, pathname)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return path.Join(util.GetCachedir(), strings.Split(rel, "/")[0])
+}
+
+/* FindRoots resolves all roots to their store-paths, stale roots are removed */
+func FindRoots() ([]string, error) {
+	var roots []string
+	err := filepath.WalkDir(rootDir(), func(link string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if entry.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+
+		resolved, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			os.Remove(link) /* the target is gone */
+			return nil
+		}
+		if root := TopLevel(resolved); root != "" {
+			roots = append(roots, root)
+			return nil
+		}
+
+		db := install.PackageDatabase{Prefix: resolved}
+		packages, err := db.Packages()
+		if err != nil {
+			return err
+		}
+		for _, pkg := range packages {
+			if root := FromHash(HashPart(pkg)); root != "" {
+				roots = append(roots, root)
+			}
+		}
+		return nil
+	})
+	return roots, err
+}
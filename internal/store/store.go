@@ -0,0 +1,158 @@
+package store
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"friedelschoen.io/paccat/internal/util"
+)
+
+const (
+	HashLength = 32 /* hex-digits of a store-hash */
+)
+
+/* Info is the metadata of a valid store-path */
+type Info struct {
+	Path       string   `json:"path"`
+	References []string `json:"references,omitempty"` /* store-paths used at runtime */
+}
+
+func metaDir() string {
+	dir := path.Join(util.GetCachedir(), ".meta")
+	os.MkdirAll(dir, 0755)
+	return dir
+}
+
+func metaPath(pathname string) string {
+	return path.Join(metaDir(), path.Base(pathname))
+}
+
+/* HashPart returns the hash-component of a store-path */
+func HashPart(pathname string) string {
+	base := path.Base(pathname)
+	if len(base) < HashLength {
+		return base
+	}
+	return base[:HashLength]
+}
+
+/* FromHash returns the valid store-path of which hash is the hash-component, or "" if there is none */
+func FromHash(hash string) string {
+	entries, _ := os.ReadDir(metaDir())
+	for _, entry := range entries {
+		if name := entry.Name(); HashPart(name) == hash && (len(name) == len(hash) || name[len(hash)] == '-') {
+			return path.Join(util.GetCachedir(), name)
+		}
+	}
+	return ""
+}
+
+/* IsValid reports whether pathname was completely realised */
+func IsValid(pathname string) bool {
+	if _, err := os.Lstat(metaPath(pathname)); err != nil {
+		return false
+	}
+	_, err := os.Lstat(pathname)
+	return err == nil
+}
+
+/* Register marks pathname as valid, it must be in its final place */
+func Register(pathname string, info *Info) error {
+	info.Path = pathname
+	content, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(metaDir(), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name()) /* does nothing if renamed */
+	defer file.Close()
+
+	if _, err := file.Write(content); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), metaPath(pathname))
+}
+
+/* Invalidate removes the validity-marker and the content of pathname */
+func Invalidate(pathname string) error {
+	if err := os.Remove(metaPath(pathname)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return RemovePath(pathname)
+}
+
+/* RemovePath removes pathname, also if it contains read-only directories */
+func RemovePath(pathname string) error {
+	filepath.WalkDir(pathname, func(current string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.IsDir() {
+			os.Chmod(current, 0755)
+		}
+		return nil
+	})
+	return os.RemoveAll(pathname)
+}
+
+/* TempPath returns an unused path in the store with the same length as pathname */
+func TempPath(pathname string) (string, error) {
+	random := make([]byte, HashLength/2)
+	for {
+		if _, err := rand.Read(random); err != nil {
+			return "", err
+		}
+		temp := path.Join(path.Dir(pathname), hex.EncodeToString(random)+path.Base(pathname)[HashLength:])
+		if _, err := os.Lstat(temp); os.IsNotExist(err) {
+			return temp, nil
+		}
+	}
+}
+
+/* Rewrite replaces every occurrence of old by new in the files and symlinks of pathname, both must have the same length */
+func Rewrite(pathname, old, new string) error {
+	oldBytes, newBytes := []byte(old), []byte(new)
+	return filepath.WalkDir(pathname, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(current)
+			if err != nil {
+				return err
+			}
+			if !bytes.Contains([]byte(target), oldBytes) {
+				return nil
+			}
+			if err := os.Remove(current); err != nil {
+				return err
+			}
+			return os.Symlink(string(bytes.ReplaceAll([]byte(target), oldBytes, newBytes)), current)
+		case entry.Type().IsRegular():
+			content, err := os.ReadFile(current)
+			if err != nil {
+				return err
+			}
+			if !bytes.Contains(content, oldBytes) {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			os.Chmod(current, info.Mode().Perm()|0200)
+			if err := os.WriteFile(current, bytes.ReplaceAll(content, oldBytes, newBytes), 0); err != nil {
+				return err
+			}
+			return os.Chmod(current, info.Mode().Perm())
+		}
+		return nil
+	})
+}
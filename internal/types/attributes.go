@@ -0,0 +1,47 @@
+package types
+
+import "iter"
+
+/* Attributes maps names to lazy values, iterating yields them in the order they were set */
+type Attributes struct {
+	names  []string
+	values map[string]*Thunk
+}
+
+func (this *Attributes) Get(name string) (*Thunk, bool) {
+	if this == nil {
+		return nil, false
+	}
+	thunk, ok := this.values[name]
+	return thunk, ok
+}
+
+func (this *Attributes) Set(name string, thunk *Thunk) {
+	if this.values == nil {
+		this.values = make(map[string]*Thunk)
+	}
+	if _, ok := this.values[name]; !ok {
+		this.names = append(this.names, name)
+	}
+	this.values[name] = thunk
+}
+
+func (this *Attributes) Len() int {
+	if this == nil {
+		return 0
+	}
+	return len(this.names)
+}
+
+func (this *Attributes) All() iter.Seq2[string, *Thunk] {
+	return func(yield func(string, *Thunk) bool) {
+		if this == nil {
+			return
+		}
+		for _, name := range this.names {
+			if !yield(name, this.values[name]) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package types
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"strings"
+	"syscall"
+
+	"friedelschoen.io/paccat/internal/sandbox"
+	"friedelschoen.io/paccat/internal/store"
+	"friedelschoen.io/paccat/internal/util"
+)
+
+type build struct {
+	interpreter string            /* program running the script, looked up in the PATH of paccat unless it contains a slash */
+	args        []string          /* arguments passed before the script-file */
+	script      string            /* script containing placeholders */
+	environ     map[string]string /* declared environment containing placeholders */
+	outputs     []buildOutput     /* paths produced by the build, the first is the main output */
+	inputs      []string          /* store-paths the build may access */
+	sha256      string            /* expected hash of fixed-outputs, these keep network-access */
+}
+
+type buildOutput struct {
+	placeholder string /* substituted by the path the builder writes to */
+	outpath     string
+}
+
+/* outpaths returns the final paths of all outputs */
+func (this *build) outpaths() []string {
+	paths := make([]string, len(this.outputs))
+	for i, output := range this.outputs {
+		paths[i] = output.outpath
+	}
+	return paths
+}
+
+/* replacer substitutes the placeholder of every output by the path in targets at the same index */
+func (this *build) replacer(targets []string) *strings.Replacer {
+	pairs := make([]string, 0, 2*len(this.outputs))
+	for i, output := range this.outputs {
+		pairs = append(pairs, output.placeholder, targets[i])
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+/* storeReferences collects all store-paths which are part of values or their nested values */
+func storeReferences(values ...Value) []string {
+	var paths []string
+	var collect func(value Value)
+	/* nested values which are not evaluated cannot contain store-paths used by the build */
+	collectForced := func(attrs *Attributes) {
+		for _, thunk := range attrs.All() {
+			if thunk.value != nil {
+				collect(thunk.value)
+			}
+		}
+	}
+	collect = func(value Value) {
+		switch this := value.(type) {
+		case *StringValue:
+			for source := range this.FlatSources() {
+				if path, ok := source.Value.(*PathValue); ok {
+					collect(path)
+				}
+			}
+		case *PathValue:
+			if !slices.Contains(paths, this.Path) {
+				paths = append(paths, this.Path)
+			}
+			collectForced(this.Exports)
+		case *ListValue:
+			for _, thunk := range this.Items {
+				if thunk.value != nil {
+					collect(thunk.value)
+				}
+			}
+		case *AttrsValue:
+			collectForced(this.Attributes)
+		}
+	}
+	for _, value := range values {
+		collect(value)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+/* run builds the outputs into temporary paths and moves them into place if the build succeeds */
+func (this *build) run(options *Options) error {
+	workdir, err := os.MkdirTemp(os.TempDir(), "paccat-workdir-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workdir) /* do remove the workdir if not needed */
+	defer util.OnInterrupt(func() { os.RemoveAll(workdir) })()
+
+	var cmd *exec.Cmd
+	targets := make([]string, len(this.outputs)) /* where the outputs land on the host */
+	removeTargets := func() {
+		for _, target := range targets {
+			if target != "" {
+				store.RemovePath(target)
+			}
+		}
+	}
+	if options == nil || !options.Sandbox {
+		/* the builder writes to temporary paths of the same length, which are rewritten afterwards */
+		for i, output := range this.outputs {
+			if targets[i], err = store.TempPath(output.outpath); err != nil {
+				return err
+			}
+		}
+		replacer := this.replacer(targets)
+		interpreter, args, err := this.command(workdir, replacer)
+		if err != nil {
+			return err
+		}
+		cmd = exec.Command(interpreter, args...)
+		cmd.Env = makeEnviron(replaceEnviron(this.environ, replacer), workdir)
+		cmd.Dir = workdir
+	} else {
+		/* the store inside the sandbox is a staging-directory, so the builder sees the real output-paths */
+		storedir := util.GetCachedir()
+		staging, err := os.MkdirTemp(storedir, ".sandbox-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(staging)
+		defer util.OnInterrupt(func() { store.RemovePath(staging) })()
+
+		root, err := os.MkdirTemp(os.TempDir(), "paccat-root-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(root)
+
+		cfg := sandbox.Config{
+			Root:    root,
+			Dir:     workdir,
+			Network: this.sha256 != "",
+			Binds: []sandbox.Bind{
+				{Source: staging, Target: storedir, Writable: true},
+				{Source: workdir, Target: workdir, Writable: true},
+			},
+		}
+		/* the inputs may refer to other store-paths at runtime, so their closure is visible */
+		closure, err := store.Closure(this.inputs)
+		if err != nil {
+			return err
+		}
+		for _, input := range closure {
+			cfg.Binds = append(cfg.Binds, sandbox.Bind{Source: input, Target: input})
+		}
+		for _, hostpath := range options.SandboxPaths {
+			cfg.Binds = append(cfg.Binds, sandbox.Bind{Source: hostpath, Target: hostpath})
+		}
+
+		replacer := this.replacer(this.outpaths())
+		interpreter, args, err := this.command(workdir, replacer)
+		if err != nil {
+			return err
+		}
+		cmd, err = sandbox.Command(&cfg, interpreter, args...)
+		if err != nil {
+			return err
+		}
+		for i, output := range this.outputs {
+			targets[i] = path.Join(staging, path.Base(output.outpath))
+		}
+		cmd.Env = makeEnviron(replaceEnviron(this.environ, replacer), workdir)
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	defer util.OnInterrupt(func() {
+		if cmd.Process != nil {
+			cmd.Process.Kill()
+		}
+		removeTargets()
+	})()
+	defer syscall.Umask(syscall.Umask(buildUmask))
+
+	if err := cmd.Run(); err != nil {
+		removeTargets()
+		return err
+	}
+	if err := this.finish(targets); err != nil {
+		removeTargets()
+		return err
+	}
+	return nil
+}
+
+/* command writes the script to a file in workdir and returns the interpreter with its arguments, replacer substitutes the placeholders */
+func (this *build) command(workdir string, replacer *strings.Replacer) (string, []string, error) {
+	scriptfile := path.Join(workdir, ".paccat-script")
+	if err := os.WriteFile(scriptfile, []byte(replacer.Replace(this.script)), 0644); err != nil {
+		return "", nil, err
+	}
+
+	/* the interpreter is looked up on the host, as the builder has no meaningful PATH */
+	interpreter, err := exec.LookPath(replacer.Replace(this.interpreter))
+	if err != nil {
+		return "", nil, err
+	}
+	args := make([]string, 0, len(this.args)+1)
+	for _, arg := range this.args {
+		args = append(args, replacer.Replace(arg))
+	}
+	return interpreter, append(args, scriptfile), nil
+}
+
+/* finish verifies the outputs at targets, moves them to their final paths and registers them */
+func (this *build) finish(targets []string) error {
+	for i, target := range targets {
+		if _, err := os.Lstat(target); err != nil {
+			return fmt.Errorf("builder did not produce output `%s`", path.Base(this.outputs[i].outpath))
+		}
+	}
+	/* outputs may refer to each other, so every output is rewritten for every temporary path */
+	for i, temp := range targets {
+		hash, final := store.HashPart(temp), store.HashPart(this.outputs[i].outpath)
+		if hash == final {
+			continue
+		}
+		for _, target := range targets {
+			if err := store.Rewrite(target, hash, final); err != nil {
+				return err
+			}
+		}
+	}
+	if this.sha256 != "" {
+		if sum, err := util.HashPath(targets[0]); err != nil {
+			return err
+		} else if sum != this.sha256 {
+			return fmt.Errorf("hash mismatch in fixed-output:\n  expected: %s\n       got: %s", this.sha256, sum)
+		}
+	}
+	references := make([][]string, len(targets))
+	for i, target := range targets {
+		/* an output may refer to the inputs and its sibling-outputs */
+		candidates := slices.Clone(this.inputs)
+		for j, output := range this.outputs {
+			if j != i {
+				candidates = append(candidates, output.outpath)
+			}
+		}
+		var err error
+		if references[i], err = store.ScanReferences(target, candidates); err != nil {
+			return err
+		}
+	}
+	for i, target := range targets {
+		if err := os.Rename(target, this.outputs[i].outpath); err != nil {
+			return err
+		}
+	}
+	for i, output := range this.outputs {
+		if err := store.Register(output.outpath, &store.Info{References: references[i]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* realise runs fn to create outpaths while holding their locks, unless all of them are valid already */
+func realise(outpaths []string, always bool, fn func() error) error {
+	valid := func() bool {
+		for _, outpath := range outpaths {
+			if !store.IsValid(outpath) {
+				return false
+			}
+		}
+		return true
+	}
+	if !always && valid() {
+		return nil
+	}
+
+	waited := false
+	for _, outpath := range outpaths {
+		lock, err := store.LockPath(outpath, func() {
+			waited = true
+			fmt.Fprintf(os.Stderr, "waiting for %s, which is being built by another process\n", outpath)
+		})
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+	}
+
+	/* the other process has just built it, so there is no need to build it again */
+	if (!always || waited) && valid() {
+		return nil
+	}
+
+	/* remove invalid remains of an interrupted build, or the previous outputs if they are always rebuilt */
+	for _, outpath := range outpaths {
+		if err := store.Invalidate(outpath); err != nil {
+			return err
+		}
+	}
+	return fn()
+}
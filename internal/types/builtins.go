@@ -0,0 +1,372 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"friedelschoen.io/paccat/internal/ast"
+	"friedelschoen.io/paccat/internal/errors"
+)
+
+/* builtins are visible in every scope, including the scope of imported files */
+var builtins map[string]*Thunk
+
+func init() {
+	functions := &Attributes{}
+	for _, builtin := range builtinFunctions {
+		functions.Set(builtin.Name, ValueThunk(&FunctionValue{Node: asLiteral(builtin.Name), Builtin: builtin}))
+	}
+	builtins = map[string]*Thunk{
+		"true":     ValueThunk(&BoolValue{Node: asLiteral("true"), Bool: true}),
+		"false":    ValueThunk(&BoolValue{Node: asLiteral("false"), Bool: false}),
+		"null":     ValueThunk(&NullValue{Node: asLiteral("null")}),
+		"builtins": ValueThunk(&AttrsValue{Node: asLiteral("builtins"), Attributes: functions}),
+	}
+}
+
+/* forceString forces thunk and converts it to a string */
+func forceString(thunk *Thunk) (*StringValue, error) {
+	value, err := thunk.Force()
+	if err != nil {
+		return nil, err
+	}
+	return coerceString(thunk.blame(value), value)
+}
+
+/* derivedString is computed from other strings, it keeps their sources as positions within content are lost */
+func derivedString(node ast.Node, content string, from ...*StringValue) *StringValue {
+	result := &StringValue{Node: node, Content: content}
+	for _, value := range from {
+		result.StringSource = append(result.StringSource, StringSource{Start: 0, Len: len(content), Value: value})
+	}
+	return result
+}
+
+func stringList(node ast.Node, parts []string, from *StringValue) *ListValue {
+	items := make([]*Thunk, len(parts))
+	for i, part := range parts {
+		items[i] = ValueThunk(derivedString(node, part, from))
+	}
+	return &ListValue{Node: node, Items: items}
+}
+
+var builtinFunctions = []*Builtin{
+	{Name: "map", Params: []string{"f", "list"}, Fn: builtinMap},
+	{Name: "filter", Params: []string{"f", "list"}, Fn: builtinFilter},
+	{Name: "foldl", Params: []string{"f", "init", "list"}, Fn: builtinFoldl},
+	{Name: "attrNames", Params: []string{"set"}, Fn: builtinAttrNames},
+	{Name: "attrValues", Params: []string{"set"}, Fn: builtinAttrValues},
+	{Name: "hasAttr", Params: []string{"name", "set"}, Fn: builtinHasAttr},
+	{Name: "length", Params: []string{"list"}, Fn: builtinLength},
+	{Name: "elemAt", Params: []string{"list", "index"}, Fn: builtinElemAt},
+	{Name: "replace", Params: []string{"from", "to", "string"}, Fn: builtinReplace},
+	{Name: "split", Params: []string{"separator", "string"}, Fn: builtinSplit},
+	{Name: "substring", Params: []string{"start", "length", "string"}, Fn: builtinSubstring},
+	{Name: "toUpper", Params: []string{"string"}, Fn: builtinToUpper},
+	{Name: "toLower", Params: []string{"string"}, Fn: builtinToLower},
+	{Name: "match", Params: []string{"regex", "string"}, Fn: builtinMatch},
+	{Name: "toString", Params: []string{"value"}, Fn: builtinToString},
+	{Name: "toInt", Params: []string{"string"}, Fn: builtinToInt},
+	{Name: "filterSource", Params: []string{"f", "path"}, Fn: builtinFilterSource},
+	{Name: "readFile", Params: []string{"path"}, Fn: builtinReadFile},
+	{Name: "fromJSON", Params: []string{"string"}, Fn: builtinFromJSON},
+	{Name: "toJSON", Params: []string{"value"}, Fn: builtinToJSON},
+	{Name: "fromTOML", Params: []string{"string"}, Fn: builtinFromTOML},
+	{Name: "tryEval", Params: []string{"value"}, Fn: builtinTryEval},
+	{Name: "try", Params: []string{"value", "fallback"}, Fn: builtinTry},
+}
+
+/* builtinMap applies f to every item of list, an item is only computed when it is used */
+func builtinMap(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	fn, err := forceAs[*FunctionValue](args[0])
+	if err != nil {
+		return nil, err
+	}
+	list, err := forceAs[*ListValue](args[1])
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*Thunk, len(list.Items))
+	for i, item := range list.Items {
+		items[i] = lazyThunk(node, func() (Value, error) {
+			return fn.apply(ctx, node, item)
+		})
+	}
+	return &ListValue{Node: node, Items: items}, nil
+}
+
+func builtinFilter(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	fn, err := forceAs[*FunctionValue](args[0])
+	if err != nil {
+		return nil, err
+	}
+	list, err := forceAs[*ListValue](args[1])
+	if err != nil {
+		return nil, err
+	}
+	var items []*Thunk
+	for _, item := range list.Items {
+		result, err := fn.apply(ctx, node, item)
+		if err != nil {
+			return nil, err
+		}
+		keep, err := expect[*BoolValue](args[0].blame(result), result)
+		if err != nil {
+			return nil, err
+		}
+		if keep.Bool {
+			items = append(items, item)
+		}
+	}
+	return &ListValue{Node: node, Items: items}, nil
+}
+
+/* builtinFoldl calls f with the accumulator and every item of list, from left to right */
+func builtinFoldl(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	fn, err := forceAs[*FunctionValue](args[0])
+	if err != nil {
+		return nil, err
+	}
+	list, err := forceAs[*ListValue](args[2])
+	if err != nil {
+		return nil, err
+	}
+	acc := args[1]
+	for _, item := range list.Items {
+		result, err := fn.apply(ctx, node, acc, item)
+		if err != nil {
+			return nil, err
+		}
+		acc = ValueThunk(result)
+	}
+	return acc.Force()
+}
+
+func builtinAttrNames(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	set, err := forceAs[*AttrsValue](args[0])
+	if err != nil {
+		return nil, err
+	}
+	var items []*Thunk
+	for name := range set.Attributes.All() {
+		items = append(items, ValueThunk(&StringValue{Node: node, Content: name}))
+	}
+	return &ListValue{Node: node, Items: items}, nil
+}
+
+func builtinAttrValues(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	set, err := forceAs[*AttrsValue](args[0])
+	if err != nil {
+		return nil, err
+	}
+	var items []*Thunk
+	for _, thunk := range set.Attributes.All() {
+		items = append(items, thunk)
+	}
+	return &ListValue{Node: node, Items: items}, nil
+}
+
+func builtinHasAttr(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	name, err := forceString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	set, err := forceAs[*AttrsValue](args[1])
+	if err != nil {
+		return nil, err
+	}
+	_, ok := set.Attributes.Get(name.Content)
+	return &BoolValue{Node: node, Bool: ok}, nil
+}
+
+func builtinLength(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	list, err := forceAs[*ListValue](args[0])
+	if err != nil {
+		return nil, err
+	}
+	return &IntValue{Node: node, Number: int64(len(list.Items))}, nil
+}
+
+func builtinElemAt(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	list, err := forceAs[*ListValue](args[0])
+	if err != nil {
+		return nil, err
+	}
+	index, err := forceAs[*IntValue](args[1])
+	if err != nil {
+		return nil, err
+	}
+	if index.Number < 0 || index.Number >= int64(len(list.Items)) {
+		return nil, errors.NewRecipeError(args[1].blame(index).GetPosition(), fmt.Sprintf("index %d is out of range for list of length %d", index.Number, len(list.Items)))
+	}
+	return list.Items[index.Number].Force()
+}
+
+func builtinReplace(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	from, err := forceString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	to, err := forceString(args[1])
+	if err != nil {
+		return nil, err
+	}
+	str, err := forceString(args[2])
+	if err != nil {
+		return nil, err
+	}
+	if from.Content == "" {
+		return nil, errors.NewRecipeError(args[0].blame(from).GetPosition(), "unable to replace an empty string")
+	}
+	return derivedString(node, strings.ReplaceAll(str.Content, from.Content, to.Content), str, to), nil
+}
+
+func builtinSplit(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	separator, err := forceString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	str, err := forceString(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if separator.Content == "" {
+		return nil, errors.NewRecipeError(args[0].blame(separator).GetPosition(), "unable to split by an empty separator")
+	}
+	return stringList(node, strings.Split(str.Content, separator.Content), str), nil
+}
+
+/* builtinSubstring returns at most length bytes of string from start, a negative length means until the end */
+func builtinSubstring(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	start, err := forceAs[*IntValue](args[0])
+	if err != nil {
+		return nil, err
+	}
+	length, err := forceAs[*IntValue](args[1])
+	if err != nil {
+		return nil, err
+	}
+	str, err := forceString(args[2])
+	if err != nil {
+		return nil, err
+	}
+	if start.Number < 0 {
+		return nil, errors.NewRecipeError(args[0].blame(start).GetPosition(), "start of substring is negative")
+	}
+	begin := min(int(start.Number), len(str.Content))
+	end := len(str.Content)
+	if length.Number >= 0 {
+		end = min(begin+int(length.Number), end)
+	}
+	return derivedString(node, str.Content[begin:end], str), nil
+}
+
+func builtinToUpper(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	str, err := forceString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return derivedString(node, strings.ToUpper(str.Content), str), nil
+}
+
+func builtinToLower(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	str, err := forceString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return derivedString(node, strings.ToLower(str.Content), str), nil
+}
+
+/* builtinMatch returns the groups of regex if it matches the whole string, otherwise null */
+func builtinMatch(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	expr, err := forceString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	str, err := forceString(args[1])
+	if err != nil {
+		return nil, err
+	}
+	regex, err := regexp.Compile("^(?:" + expr.Content + ")$")
+	if err != nil {
+		return nil, errors.WrapRecipeError(err, args[0].blame(expr).GetPosition(), "invalid regular expression")
+	}
+	match := regex.FindStringSubmatchIndex(str.Content)
+	if match == nil {
+		return &NullValue{Node: node}, nil
+	}
+	var items []*Thunk
+	for i := 2; i < len(match); i += 2 {
+		if match[i] < 0 {
+			items = append(items, ValueThunk(&NullValue{Node: node}))
+		} else {
+			items = append(items, ValueThunk(derivedString(node, str.Content[match[i]:match[i+1]], str)))
+		}
+	}
+	return &ListValue{Node: node, Items: items}, nil
+}
+
+/* builtinToString converts value to a string, unlike interpolation it accepts booleans and null */
+func builtinToString(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	value, err := args[0].Force()
+	if err != nil {
+		return nil, err
+	}
+	switch value := value.(type) {
+	case *BoolValue:
+		if value.Bool {
+			return &StringValue{Node: node, Content: "1"}, nil
+		}
+		return &StringValue{Node: node, Content: ""}, nil
+	case *NullValue:
+		return &StringValue{Node: node, Content: ""}, nil
+	}
+	return coerceString(args[0].blame(value), value)
+}
+
+func builtinToInt(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	str, err := forceString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	number, err := strconv.ParseInt(strings.TrimSpace(str.Content), 10, 64)
+	if err != nil {
+		return nil, errors.NewRecipeError(args[0].blame(str).GetPosition(), fmt.Sprintf("`%s` is not an integer", str.Content))
+	}
+	return &IntValue{Node: node, Number: number}, nil
+}
+
+/* rootCause returns the message of the innermost error of the trace of err */
+func rootCause(err error) string {
+	for {
+		ctxErr, ok := err.(errors.ContextError)
+		if !ok || ctxErr.Previous() == nil {
+			return err.Error()
+		}
+		err = ctxErr.Previous()
+	}
+}
+
+/* builtinTryEval evaluates value and reports whether it succeeded, nested values are not evaluated */
+func builtinTryEval(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	result := &Attributes{}
+	value, err := args[0].Force()
+	if err != nil {
+		result.Set("success", ValueThunk(&BoolValue{Node: node, Bool: false}))
+		result.Set("error", ValueThunk(&StringValue{Node: node, Content: rootCause(err)}))
+	} else {
+		result.Set("success", ValueThunk(&BoolValue{Node: node, Bool: true}))
+		result.Set("value", ValueThunk(value))
+	}
+	return &AttrsValue{Node: node, Attributes: result}, nil
+}
+
+/* builtinTry evaluates to value, or to fallback if evaluating value fails */
+func builtinTry(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	if value, err := args[0].Force(); err == nil {
+		return value, nil
+	}
+	return args[1].Force()
+}
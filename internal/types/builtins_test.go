@@ -0,0 +1,81 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuiltins(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string /* representation of the result */
+	}{
+		{`builtins.map(f = (x) -> x * 2, list = [ 1, 2, 3 ])`, `[ 2, 4, 6 ]`},
+		{`builtins.length(list = builtins.map(f = (x) -> panic "unused", list = [ 1, 2 ]))`, `2`},
+		{`builtins.filter(f = (x) -> x > 1, list = [ 1, 2, 3 ])`, `[ 2, 3 ]`},
+		{`builtins.foldl(f = (acc, x) -> acc ++ x, init = "", list = [ "a", "b", "c" ])`, `"abc"`},
+		{`builtins.attrNames(set = { b = 1, a = 2 })`, `[ "b", "a" ]`},
+		{`builtins.attrValues(set = { b = 1, a = 2 })`, `[ 1, 2 ]`},
+		{`builtins.hasAttr(name = "a", set = { a = 1 })`, `true`},
+		{`builtins.hasAttr(name = "b", set = { a = 1 })`, `false`},
+		{`builtins.length(list = [ 1, 2, 3 ])`, `3`},
+		{`builtins.elemAt(list = [ "a", "b" ], index = 1)`, `"b"`},
+		{`builtins.replace(from = "o", to = "0", string = "foo")`, `"f00"`},
+		{`builtins.split(separator = ",", string = "a,b,,c")`, `[ "a", "b", "", "c" ]`},
+		{`builtins.substring(start = 1, length = 3, string = "paccat")`, `"acc"`},
+		{`builtins.substring(start = 3, length = -1, string = "paccat")`, `"cat"`},
+		{`builtins.substring(start = 10, length = 2, string = "paccat")`, `""`},
+		{`builtins.toUpper(string = "dwm")`, `"DWM"`},
+		{`builtins.toLower(string = "DWM")`, `"dwm"`},
+		{`builtins.match(regex = "([a-z]+)-([0-9.]+)", string = "dwm-6.5")`, `[ "dwm", "6.5" ]`},
+		{`builtins.match(regex = "[0-9]+", string = "dwm")`, `null`},
+		{`builtins.toString(value = true) ++ builtins.toString(value = false) ++ builtins.toString(value = null)`, `"1"`},
+		{`builtins.toString(value = 42)`, `"42"`},
+		{`builtins.toInt(string = " 42 ")`, `42`},
+	}
+	for _, test := range tests {
+		value, err := evalSource(t, test.source)
+		if err != nil {
+			t.Errorf("`%s`: unexpected error: %v", test.source, rootCause(err))
+			continue
+		}
+		got, err := Repr(value)
+		if err != nil {
+			t.Errorf("`%s`: unable to represent result: %v", test.source, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("`%s` = %s, want %s", test.source, got, test.want)
+		}
+	}
+}
+
+func TestBuiltinErrors(t *testing.T) {
+	tests := []struct {
+		source  string
+		message string
+		at      string /* the error is reported at the first occurrence of at */
+	}{
+		{`builtins.elemAt(list = [ 1, 2 ], index = 2)`, "index 2 is out of range for list of length 2", "2)"},
+		{`builtins.elemAt(list = [ 1 ], index = "0")`, "expected int but got string", `"0"`},
+		{`builtins.substring(start = -1, length = 1, string = "abc")`, "start of substring is negative", "-1"},
+		{`builtins.match(regex = "(", string = "abc")`, "invalid regular expression", `"("`},
+		{`builtins.toInt(string = "x")`, "`x` is not an integer", `"x"`},
+		{`builtins.split(separator = "", string = "abc")`, "unable to split by an empty separator", `""`},
+		{`builtins.length(list = { a = 1 })`, "expected list but got attrset", "{"},
+		{`builtins.length()`, "length called without parameter `list`", "()"},
+	}
+	for _, test := range tests {
+		_, err := evalSource(t, test.source)
+		if err == nil {
+			t.Errorf("`%s`: expected an error", test.source)
+			continue
+		}
+		if !strings.Contains(rootCause(err), test.message) {
+			t.Errorf("`%s`: error = %q, want %q", test.source, rootCause(err), test.message)
+		}
+		if start, want := errorStart(err), strings.Index(test.source, test.at); start != want {
+			t.Errorf("`%s`: error at offset %d, want %d", test.source, start, want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"friedelschoen.io/paccat/internal/ast"
+	"friedelschoen.io/paccat/internal/errors"
+)
+
+func typeError(node ast.Node, value Value, expected string) error {
+	return errors.NewRecipeError(node.GetPosition(), fmt.Sprintf("expected %s but got %s", expected, value.TypeName()))
+}
+
+/* expect returns value as T, node is the expression which is blamed if it is not */
+func expect[T Value](node ast.Node, value Value) (T, error) {
+	result, ok := value.(T)
+	if !ok {
+		return result, typeError(node, value, result.TypeName())
+	}
+	return result, nil
+}
+
+/* evaluateAs evaluates node, which must result in a T */
+func evaluateAs[T Value](ctx Scope, node ast.Node) (T, error) {
+	value, err := ctx.Evaluate(node)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return expect[T](node, value)
+}
+
+/* forceAs forces thunk, which must result in a T */
+func forceAs[T Value](thunk *Thunk) (T, error) {
+	value, err := thunk.Force()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return expect[T](thunk.blame(value), value)
+}
+
+/* coerceString converts value to a string, store-paths stay a source of the result */
+func coerceString(node ast.Node, value Value) (*StringValue, error) {
+	switch this := value.(type) {
+	case *StringValue:
+		return this, nil
+	case *PathValue:
+		return &StringValue{
+			Node:         this.Node,
+			Content:      this.Path,
+			StringSource: []StringSource{{Start: 0, Len: len(this.Path), Value: this}},
+		}, nil
+	case *SourceValue:
+		stored, err := this.store()
+		if err != nil {
+			return nil, errors.WrapRecipeError(err, node.GetPosition(), "unable to copy source into the store")
+		}
+		return coerceString(node, stored)
+	case *IntValue:
+		return &StringValue{
+			Node:    this.Node,
+			Content: strconv.FormatInt(this.Number, 10),
+		}, nil
+	case *ListValue:
+		/* items are separated by spaces, items containing spaces are quoted */
+		builder := ValueBuilder{}
+		for i, thunk := range this.Items {
+			item, err := thunk.Force()
+			if err != nil {
+				return nil, err
+			}
+			str, err := coerceString(thunk.blame(item), item)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				builder.WriteByte(' ')
+			}
+			builder.WriteValue(str, true)
+		}
+		return builder.Value(this.Node), nil
+	default:
+		return nil, typeError(node, value, "string")
+	}
+}
+
+/* evaluateString evaluates node and converts the result to a string */
+func (ctx Scope) evaluateString(node ast.Node) (*StringValue, error) {
+	value, err := ctx.Evaluate(node)
+	if err != nil {
+		return nil, err
+	}
+	return coerceString(node, value)
+}
+
+/* Repr formats value the way it is written in a recipe, nested values are forced */
+func Repr(value Value) (string, error) {
+	builder := &strings.Builder{}
+	if err := writeRepr(builder, value); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+func writeRepr(builder *strings.Builder, value Value) error {
+	switch this := value.(type) {
+	case *StringValue:
+		builder.WriteString(strconv.Quote(this.Content))
+	case *PathValue:
+		builder.WriteString(this.Path)
+	case *SourceValue:
+		builder.WriteString(this.Path)
+	case *IntValue:
+		builder.WriteString(strconv.FormatInt(this.Number, 10))
+	case *BoolValue:
+		builder.WriteString(strconv.FormatBool(this.Bool))
+	case *NullValue:
+		builder.WriteString("null")
+	case *FunctionValue:
+		builder.WriteString("<function>")
+	case *ListValue:
+		builder.WriteString("[")
+		for i, thunk := range this.Items {
+			if i > 0 {
+				builder.WriteString(",")
+			}
+			builder.WriteString(" ")
+			item, err := thunk.Force()
+			if err != nil {
+				return err
+			}
+			if err := writeRepr(builder, item); err != nil {
+				return err
+			}
+		}
+		builder.WriteString(" ]")
+	case *AttrsValue:
+		builder.WriteString("{")
+		i := 0
+		for name, thunk := range this.Attributes.All() {
+			if i > 0 {
+				builder.WriteString(",")
+			}
+			i++
+			fmt.Fprintf(builder, " %s = ", name)
+			item, err := thunk.Force()
+			if err != nil {
+				return err
+			}
+			if err := writeRepr(builder, item); err != nil {
+				return err
+			}
+		}
+		builder.WriteString(" }")
+	}
+	return nil
+}
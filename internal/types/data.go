@@ -0,0 +1,245 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"friedelschoen.io/paccat/internal/ast"
+	"friedelschoen.io/paccat/internal/errors"
+	"github.com/BurntSushi/toml"
+)
+
+/* builtinReadFile reads a file relative to the recipe */
+func builtinReadFile(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	name, err := args[0].Force()
+	if err != nil {
+		return nil, err
+	}
+	pathname, err := hostPath(node, args[0].blame(name), name)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(pathname)
+	if err != nil {
+		return nil, errors.WrapRecipeError(err, args[0].blame(name).GetPosition(), "unable to read file")
+	}
+	return &StringValue{Node: node, Content: string(content)}, nil
+}
+
+func builtinFromJSON(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	str, err := forceString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(strings.NewReader(str.Content))
+	decoder.UseNumber()
+	value, err := decodeJSON(node, decoder)
+	if err == nil {
+		if _, err = decoder.Token(); err == io.EOF {
+			return value, nil
+		} else if err == nil {
+			err = fmt.Errorf("unexpected data after value")
+		}
+	}
+	return nil, errors.WrapRecipeError(err, args[0].blame(str).GetPosition(), "invalid JSON")
+}
+
+/* decodeJSON reads the next value from decoder, object keys keep their order */
+func decodeJSON(node ast.Node, decoder *json.Decoder) (Value, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token := token.(type) {
+	case json.Delim:
+		switch token {
+		case '[':
+			list := &ListValue{Node: node}
+			for decoder.More() {
+				item, err := decodeJSON(node, decoder)
+				if err != nil {
+					return nil, err
+				}
+				list.Items = append(list.Items, ValueThunk(item))
+			}
+			_, err := decoder.Token()
+			return list, err
+		case '{':
+			attrs := &Attributes{}
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return nil, err
+				}
+				item, err := decodeJSON(node, decoder)
+				if err != nil {
+					return nil, err
+				}
+				attrs.Set(key.(string), ValueThunk(item))
+			}
+			_, err := decoder.Token()
+			return &AttrsValue{Node: node, Attributes: attrs}, err
+		}
+		return nil, fmt.Errorf("unexpected `%v`", token)
+	case string:
+		return &StringValue{Node: node, Content: token}, nil
+	case json.Number:
+		number, err := token.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("number %s is not an integer", token)
+		}
+		return &IntValue{Node: node, Number: number}, nil
+	case bool:
+		return &BoolValue{Node: node, Bool: token}, nil
+	default:
+		return &NullValue{Node: node}, nil
+	}
+}
+
+/* builtinToJSON encodes value, store-paths in it stay sources of the result */
+func builtinToJSON(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	value, err := args[0].Force()
+	if err != nil {
+		return nil, err
+	}
+	var sources []*StringValue
+	builder := &strings.Builder{}
+	if err := encodeJSON(builder, args[0].blame(value), value, &sources); err != nil {
+		return nil, err
+	}
+	return derivedString(node, builder.String(), sources...), nil
+}
+
+func encodeJSON(builder *strings.Builder, node ast.Node, value Value, sources *[]*StringValue) error {
+	switch this := value.(type) {
+	case *StringValue, *PathValue, *SourceValue:
+		str, err := coerceString(node, this)
+		if err != nil {
+			return err
+		}
+		if len(str.StringSource) > 0 {
+			*sources = append(*sources, str)
+		}
+		/* json.Marshal would escape <, > and & */
+		buffer := &bytes.Buffer{}
+		encoder := json.NewEncoder(buffer)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(str.Content); err != nil {
+			return err
+		}
+		builder.Write(bytes.TrimSuffix(buffer.Bytes(), []byte("\n")))
+	case *IntValue:
+		fmt.Fprint(builder, this.Number)
+	case *BoolValue:
+		fmt.Fprint(builder, this.Bool)
+	case *NullValue:
+		builder.WriteString("null")
+	case *ListValue:
+		builder.WriteByte('[')
+		for i, thunk := range this.Items {
+			if i > 0 {
+				builder.WriteByte(',')
+			}
+			item, err := thunk.Force()
+			if err != nil {
+				return err
+			}
+			if err := encodeJSON(builder, thunk.blame(item), item, sources); err != nil {
+				return err
+			}
+		}
+		builder.WriteByte(']')
+	case *AttrsValue:
+		builder.WriteByte('{')
+		i := 0
+		for name, thunk := range this.Attributes.All() {
+			if i > 0 {
+				builder.WriteByte(',')
+			}
+			i++
+			key, _ := json.Marshal(name)
+			builder.Write(key)
+			builder.WriteByte(':')
+			item, err := thunk.Force()
+			if err != nil {
+				return err
+			}
+			if err := encodeJSON(builder, thunk.blame(item), item, sources); err != nil {
+				return err
+			}
+		}
+		builder.WriteByte('}')
+	default:
+		return errors.NewRecipeError(node.GetPosition(), fmt.Sprintf("unable to convert %s to JSON", value.TypeName()))
+	}
+	return nil
+}
+
+func builtinFromTOML(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	str, err := forceString(args[0])
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]any
+	if _, err := toml.Decode(str.Content, &data); err != nil {
+		return nil, errors.WrapRecipeError(err, args[0].blame(str).GetPosition(), "invalid TOML")
+	}
+	value, err := convertTOML(node, data)
+	if err != nil {
+		return nil, errors.WrapRecipeError(err, args[0].blame(str).GetPosition(), "invalid TOML")
+	}
+	return value, nil
+}
+
+/* convertTOML converts a decoded TOML value, tables are sorted by key */
+func convertTOML(node ast.Node, data any) (Value, error) {
+	switch data := data.(type) {
+	case map[string]any:
+		attrs := &Attributes{}
+		for _, key := range slices.Sorted(maps.Keys(data)) {
+			item, err := convertTOML(node, data[key])
+			if err != nil {
+				return nil, err
+			}
+			attrs.Set(key, ValueThunk(item))
+		}
+		return &AttrsValue{Node: node, Attributes: attrs}, nil
+	case []map[string]any:
+		list := &ListValue{Node: node}
+		for _, table := range data {
+			item, err := convertTOML(node, table)
+			if err != nil {
+				return nil, err
+			}
+			list.Items = append(list.Items, ValueThunk(item))
+		}
+		return list, nil
+	case []any:
+		list := &ListValue{Node: node}
+		for _, elem := range data {
+			item, err := convertTOML(node, elem)
+			if err != nil {
+				return nil, err
+			}
+			list.Items = append(list.Items, ValueThunk(item))
+		}
+		return list, nil
+	case string:
+		return &StringValue{Node: node, Content: data}, nil
+	case int64:
+		return &IntValue{Node: node, Number: data}, nil
+	case bool:
+		return &BoolValue{Node: node, Bool: data}, nil
+	case time.Time:
+		return &StringValue{Node: node, Content: data.Format(time.RFC3339Nano)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported value %v", data)
+	}
+}
@@ -0,0 +1,93 @@
+package types
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"friedelschoen.io/paccat/internal/ast"
+)
+
+const (
+	buildUmask = 0022
+)
+
+/* fixedEnviron is part of every build, declared variables take precedence */
+var fixedEnviron = map[string]string{
+	"HOME":              "/homeless-shelter",
+	"LANG":              "C",
+	"PATH":              "/path-not-set",
+	"SOURCE_DATE_EPOCH": "0",
+	"TZ":                "UTC",
+}
+
+/* asDependencies accepts a list of dependencies or a single store-path */
+func asDependencies(node ast.Node, value Value) (*ListValue, error) {
+	switch this := value.(type) {
+	case *ListValue:
+		return this, nil
+	case *PathValue:
+		return &ListValue{Node: this.Node, Items: []*Thunk{ValueThunk(this)}}, nil
+	default:
+		return nil, typeError(node, value, "list or store path")
+	}
+}
+
+/* dependencyPaths forces the items of deps, which must be store-paths */
+func dependencyPaths(deps *ListValue) ([]*PathValue, error) {
+	if deps == nil {
+		return nil, nil
+	}
+	paths := make([]*PathValue, len(deps.Items))
+	for i, thunk := range deps.Items {
+		dep, err := forceAs[*PathValue](thunk)
+		if err != nil {
+			return nil, err
+		}
+		paths[i] = dep
+	}
+	return paths, nil
+}
+
+/* dependencyEnviron collects the variables exported by deps, which are relative to the first output of a dependency */
+func dependencyEnviron(deps []*PathValue) (map[string]string, error) {
+	environ := map[string]string{}
+	for _, dep := range deps {
+		for name := range dep.Exports.All() {
+			value, err := forceField(dep.Exports, name, coerceString)
+			if err != nil {
+				return nil, err
+			}
+			root := dep.exportRoot().Path
+			if prev, ok := environ[name]; ok {
+				environ[name] = fmt.Sprintf("%s:%s/%s", prev, root, value.Content)
+			} else {
+				environ[name] = root + "/" + value.Content
+			}
+		}
+	}
+	return environ, nil
+}
+
+func replaceEnviron(environ map[string]string, replacer *strings.Replacer) map[string]string {
+	result := make(map[string]string, len(environ))
+	for key, value := range environ {
+		result[key] = replacer.Replace(value)
+	}
+	return result
+}
+
+/* makeEnviron returns the sorted environment of a build running in tmpdir */
+func makeEnviron(declared map[string]string, tmpdir string) []string {
+	environ := maps.Clone(fixedEnviron)
+	maps.Copy(environ, declared)
+	environ["TMPDIR"] = tmpdir
+
+	keys := slices.Sorted(maps.Keys(environ))
+	result := make([]string, len(keys))
+	for i, key := range keys {
+		result[i] = key + "=" + environ[key]
+	}
+	return result
+}
@@ -2,322 +2,227 @@ package types
 
 import (
 	"fmt"
-	"math"
-	"os"
-	"os/exec"
-	"path"
+	"path/filepath"
+	"slices"
 	"strconv"
-	"strings"
 
 	"friedelschoen.io/paccat/internal/ast"
 	"friedelschoen.io/paccat/internal/errors"
-	"friedelschoen.io/paccat/internal/parser"
-	"friedelschoen.io/paccat/internal/util"
-	"github.com/agnivade/levenshtein"
 )
 
-const (
-	MaxSimilarityDistance = 3
-)
-
-type Variable struct {
-	name string
-	node ast.Node
-}
-
-type Scope []Variable
-
-func (this Scope) findSimilar(name string) (string, int) {
-	lowest := ""
-	lowestDist := math.MaxInt
-	for _, current := range this {
-		if dist := levenshtein.ComputeDistance(name, current.name); dist < lowestDist {
-			lowest = current.name
-			lowestDist = dist
-		}
+/* call passes the arguments of this to the called function, arguments are evaluated in the scope of the caller */
+func (ctx Scope) call(this *ast.CallNode) (Value, error) {
+	target, err := ctx.Evaluate(this.Target)
+	if err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "unable to call "+this.Target.Name())
 	}
-	return lowest, lowestDist
-}
-
-func (ctx Scope) Get(name string) ast.Node {
-	for _, variable := range ctx {
-		if variable.name == name {
-			return variable.node
-		}
+	function, err := expect[*FunctionValue](this.Target, target)
+	if err != nil {
+		return nil, err
 	}
-	return nil
-}
 
-func (ctx Scope) Set(name string, value ast.Node) Scope {
-	newctx := make([]Variable, 0, len(ctx))
-	for _, variable := range ctx {
-		if variable.name != name {
-			newctx = append(newctx, variable)
+	params := function.params()
+	args := make(map[string]*Thunk, len(this.Args))
+	for _, arg := range this.Args {
+		if !slices.Contains(params, arg.Key.Content) {
+			return nil, errors.NewRecipeError(arg.Key.GetPosition(), fmt.Sprintf("function has no parameter `%s`", arg.Key.Content))
 		}
+		args[arg.Key.Content] = NewThunk(arg.Value, ctx)
 	}
-	if value != nil {
-		newctx = append(newctx, Variable{name, value})
-	}
-	return newctx
+	return function.invoke(ctx, this, args)
 }
 
-func asLiteral(content string) *ast.LiteralNode {
-	return &ast.LiteralNode{
-		Pos: errors.Position{
-			File:  &errors.ErrorFile{Filename: "<eval>", Content: content},
-			Start: 0,
-			End:   len(content)},
-		Content: content,
+/* getAttribute returns the attribute of an attrset or store-path, or the element of a list */
+func (ctx Scope) getAttribute(this *ast.GetterNode) (Value, error) {
+	target, err := ctx.Evaluate(this.Target)
+	if err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while trying to get attribute")
+	}
+	attr, err := ctx.Evaluate(this.Attribute)
+	if err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while trying to get attribute")
 	}
-}
-
-func (ctx Scope) SetLiteral(name string, content string) Scope {
-	return ctx.Set(name, asLiteral(content))
-}
-
-func (ctx Scope) Unwrap(currentNode ast.Node) (ast.Node, Scope, error) {
-	for {
-		switch this := currentNode.(type) {
-		case *ast.ImportNode:
-			filename, err := ctx.Evaluate(this.Source)
-			if err != nil {
-				return nil, nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating import")
-			}
-
-			workdir := path.Dir(this.Pos.File.Filename)
-			pathname := path.Join(workdir, filename.Content)
-			currentNode, err = parser.ParseFile(pathname)
-			if err != nil {
-				return nil, nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating import")
-			}
-			ctx = []Variable{}
-		case *ast.CallNode:
-			var target ast.Node
-			var err error
-			target, ctx, err = ctx.Unwrap(this.Target)
-			if err != nil {
-				return nil, nil, errors.WrapRecipeError(err, this.GetPosition(), "unable to call "+this.Target.Name())
-			}
-			lambda, ok := target.(*ast.LambdaNode)
-			if !ok {
-				return nil, nil, errors.NewRecipeError(this.GetPosition(), "unable to call "+this.Target.Name())
-			}
 
-			for key, def := range lambda.Args {
-				if val, ok := this.Args[key]; ok {
-					ctx = ctx.Set(key, val.Value)
-				} else if def.Value != nil {
-					ctx = ctx.Set(key, def.Value)
-				} else {
-					return nil, nil, errors.NewRecipeError(this.GetPosition(), fmt.Sprintf("lambda called without parameter `%s`", key))
-				}
-			}
-			currentNode = lambda.Target
-		case *ast.ReferenceNode:
-			currentNode = ctx.Get(this.Variable.Content)
-			if currentNode == nil {
-				similar, dist := ctx.findSimilar(this.Variable.Content)
-				if dist <= MaxSimilarityDistance {
-					return nil, nil, errors.NewRecipeError(this.GetPosition(), fmt.Sprintf("`%s` is not defined in current scope, do you mean `%s`?", this.Variable.Content, similar))
-				}
-				return nil, nil, errors.NewRecipeError(this.GetPosition(), fmt.Sprintf("`%s` is not defined in current scope", this.Variable.Content))
-			}
-		default:
-			return currentNode, ctx, nil
+	byName := func(get func(string) (*Thunk, bool)) (*Thunk, error) {
+		name, err := expect[*StringValue](this.Attribute, attr)
+		if err != nil {
+			return nil, err
 		}
+		thunk, ok := get(name.Content)
+		if !ok {
+			return nil, errors.NewRecipeError(this.GetPosition(), fmt.Sprintf("target has no attribute `%s`", name.Content))
+		}
+		return thunk, nil
 	}
-}
 
-func makeEnviron(deps *StringValue) []string {
-	if deps == nil {
-		return os.Environ()
-	}
-	environ := map[string]string{}
-	for _, env := range os.Environ() {
-		spl := strings.SplitN(env, "=", 2)
-		if len(spl) != 2 {
-			continue
+	var thunk *Thunk
+	switch target := target.(type) {
+	case *AttrsValue:
+		if thunk, err = byName(target.Attributes.Get); err != nil {
+			return nil, err
 		}
-		environ[spl[0]] = spl[1]
-	}
-	for content, dep := range deps.Split() {
-		if dep == nil {
-			continue
+	case *PathValue:
+		if thunk, err = byName(target.Get); err != nil {
+			return nil, err
 		}
-		for name, value := range dep.Attributes {
-			if prev, ok := environ[name]; ok {
-				environ[name] = fmt.Sprintf("%s:%s/%s", prev, content, value.Content)
-			} else {
-				environ[name] = content + "/" + value.Content
-			}
+	case *ListValue:
+		index, err := expect[*IntValue](this.Attribute, attr)
+		if err != nil {
+			return nil, err
+		}
+		if index.Number < 0 || index.Number >= int64(len(target.Items)) {
+			return nil, errors.NewRecipeError(this.Attribute.GetPosition(), fmt.Sprintf("index %d is out of range for list of length %d", index.Number, len(target.Items)))
 		}
+		thunk = target.Items[index.Number]
+	default:
+		return nil, typeError(this.Target, target, "attrset or list")
 	}
-	result := make([]string, len(environ))
-	i := 0
-	for key, value := range environ {
-		result[i] = key + "=" + value
-		i++
+
+	value, err := thunk.Force()
+	if err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while trying to get attribute")
 	}
-	return result
+	return value, nil
 }
 
-func (ctx Scope) Evaluate(currentNode ast.Node) (*StringValue, error) {
-	currentNode, ctx, err := ctx.Unwrap(currentNode)
-	if err != nil {
-		return nil, err
+/* depth is the number of evaluations in progress */
+var depth int
+
+func (ctx Scope) Evaluate(currentNode ast.Node) (Value, error) {
+	maxDepth := DefaultMaxDepth
+	if ctx.options != nil && ctx.options.MaxDepth > 0 {
+		maxDepth = ctx.options.MaxDepth
+	}
+	if depth >= maxDepth {
+		return nil, &recursionError{currentNode.GetPosition(), fmt.Sprintf("maximum evaluation depth of %d exceeded, possibly infinite recursion", maxDepth)}
 	}
+	depth++
+	defer func() { depth-- }()
+	return ctx.evaluate(currentNode)
+}
+
+func (ctx Scope) evaluate(currentNode ast.Node) (Value, error) {
 	switch this := currentNode.(type) {
-	case *ast.GetterNode:
-		value, err := ctx.Evaluate(this.Target)
+	case *ast.ReferenceNode:
+		thunk, err := ctx.lookup(this)
 		if err != nil {
-			return nil, errors.WrapRecipeError(err, this.GetPosition(), "while trying to get attribute")
+			return nil, err
 		}
-
-		attr, err := ctx.Evaluate(this.Attribute)
-		if err != nil {
-			return nil, errors.WrapRecipeError(err, this.GetPosition(), "while trying to get attribute")
+		if thunk.evaluating {
+			return nil, &recursionError{this.GetPosition(), fmt.Sprintf("infinite recursion, `%s` depends on itself", this.Variable.Content)}
 		}
-		res, ok := value.Attributes[attr.Content]
-		if !ok {
-			return nil, errors.NewRecipeError(this.GetPosition(), fmt.Sprintf("target has no attribute `%s`", attr.Content))
+		value, err := thunk.Force()
+		if isRecursion(err) {
+			/* the trace shows every reference leading to the recursion */
+			return nil, errors.WrapRecipeError(err, this.GetPosition(), fmt.Sprintf("while evaluating `%s`", this.Variable.Content))
 		}
-		return res, nil
+		return value, err
+	case *ast.ImportNode:
+		return ctx.evaluateImport(this)
+	case *ast.CallNode:
+		return ctx.call(this)
+	case *ast.LambdaNode:
+		/* a lambda captures the scope it is defined in */
+		return &FunctionValue{
+			Node:   this,
+			Lambda: this,
+			Scope:  ctx,
+		}, nil
+	case *ast.GetterNode:
+		return ctx.getAttribute(this)
 	case *ast.DictNode:
-		values := map[string]*StringValue{}
-		for key, itempair := range this.Items {
-			if err != nil {
-				return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluting dict")
-			}
-			values[key], err = ctx.Evaluate(itempair.Value)
-			if err != nil {
-				return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluting dict")
-			}
+		/* items are only evaluated when they are used */
+		if this.Recursive {
+			_, values := ctx.bindRecursive(this.Items)
+			return &AttrsValue{
+				Node:       this,
+				Attributes: values,
+			}, nil
 		}
-		return &StringValue{
+		values := &Attributes{}
+		for _, pair := range this.Items {
+			values.Set(pair.Key.Content, NewThunk(pair.Value, ctx))
+		}
+		return &AttrsValue{
 			Node:       this,
 			Attributes: values,
 		}, nil
+	case *ast.LetNode:
+		letCtx, _ := ctx.bindRecursive(this.Bindings)
+		return letCtx.Evaluate(this.Target)
 	case *ast.ListNode:
-		builder := ValueBuilder{}
-		attrs := make(map[string]*StringValue)
+		items := make([]*Thunk, len(this.Items))
 		for i, item := range this.Items {
-			if i > 0 {
-				builder.WriteByte(' ')
-			}
-			anyValue, err := ctx.Evaluate(item)
-			if err != nil {
-				return nil, errors.WrapRecipeError(err, this.Pos, "while evaluating list")
-			}
-			builder.WriteValue(anyValue, true)
-			istr := strconv.Itoa(i)
-			attrs[istr] = anyValue
+			items[i] = NewThunk(item, ctx)
 		}
-		res := builder.Value(this)
-		res.Attributes = attrs
-		return res, nil
+		return &ListValue{
+			Node:  this,
+			Items: items,
+		}, nil
 	case *ast.LiteralNode:
 		return &StringValue{
 			Node:    this,
 			Content: this.Content,
 		}, nil
-	case *ast.NumberNode:
-		return &StringValue{
-			Node:    this,
-			Content: this.Content.Content,
-		}, nil
-	case *ast.OutputNode:
-		var scriptEval ast.Node
-		if opt, ok := this.Options.(*ast.DictNode); ok {
-			for key, value := range opt.Items {
-				if key == "script" {
-					scriptEval = value.Value
-				}
-				ctx = ctx.Set(key, value.Value)
-			}
-			if scriptEval == nil {
-				return nil, errors.NewRecipeError(this.GetPosition(), "output requires field `script`")
-			}
-		} else {
-			scriptEval = this.Options
-		}
-
-		sum := ast.NodeHash(this)
-		outpath := path.Join(util.GetCachedir(), sum)
-
-		if _, err := os.Stat(outpath); err == nil {
-			if alwaysEval := ctx.Get("always"); alwaysEval != nil {
-				alwaysVal, err := ctx.Evaluate(alwaysEval)
-				if err != nil {
-					return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating output")
-				}
-				if len(alwaysVal.Content) > 0 {
-					return &StringValue{
-						Node:    this,
-						Content: outpath,
-					}, nil
-				}
-			}
-			if err = os.RemoveAll(outpath); err != nil {
-				return nil, errors.WrapRecipeError(err, this.GetPosition(), "while cleaning output")
-			}
-		}
-
-		workdir, err := os.MkdirTemp(os.TempDir(), "paccat-workdir-")
+	case *ast.PathNode:
+		pathname, err := filepath.Abs(resolvePath(this, this.Content.Content))
 		if err != nil {
-			return nil, err
+			return nil, errors.WrapRecipeError(err, this.GetPosition(), "unable to resolve path")
 		}
-		defer os.RemoveAll(workdir) /* do remove the workdir if not needed */
-
-		ctx = ctx.SetLiteral("out", outpath)
-
-		var deps *StringValue
-		if depsNode := ctx.Get("depends"); depsNode != nil {
-			deps, err = ctx.Evaluate(depsNode)
-			if err != nil {
-				return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating dependencies")
-			}
-		}
-
-		var exports map[string]*StringValue
-		if exportsNode := ctx.Get("exports"); exportsNode != nil {
-			exp, err := ctx.Evaluate(exportsNode)
-			if err != nil {
-				return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating dependencies")
-			}
-			exports = exp.Attributes
-		}
-
-		scriptValue, err := ctx.Evaluate(scriptEval)
+		return &SourceValue{
+			Node: this,
+			Path: pathname,
+		}, nil
+	case *ast.LookupNode:
+		return ctx.searchPath(this)
+	case *ast.NumberNode:
+		number, err := strconv.ParseInt(this.Content.Content, 10, 64)
 		if err != nil {
-			return nil, errors.WrapRecipeError(err, scriptEval.GetPosition(), "while evaluating output")
-		}
-
-		cmd := exec.Command("sh")
-		cmd.Stdin = strings.NewReader(scriptValue.Content)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Env = makeEnviron(deps)
-		cmd.Dir = workdir
-		if err = cmd.Run(); err != nil {
-			return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating output")
+			return nil, errors.NewRecipeError(this.GetPosition(), fmt.Sprintf("invalid number `%s`", this.Content.Content))
 		}
-
-		return &StringValue{
-			Node:       this,
-			Content:    outpath,
-			Attributes: exports,
+		return &IntValue{
+			Node:   this,
+			Number: number,
 		}, nil
+	case *ast.BinaryNode:
+		return ctx.evaluateBinary(this)
+	case *ast.UnaryNode:
+		return ctx.evaluateUnary(this)
+	case *ast.OutputNode:
+		return ctx.evaluateOutput(this)
+	case *ast.FetchNode:
+		return ctx.evaluateFetch(this)
 	case *ast.PanicNode:
-		value, err := ctx.Evaluate(this.Message)
+		value, err := ctx.evaluateString(this.Message)
 		if err != nil {
 			return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating panic")
 		}
 
 		return nil, errors.NewRecipeError(this.GetPosition(), value.Content)
+	case *ast.IfNode:
+		/* only the taken branch is evaluated */
+		condition, err := evaluateAs[*BoolValue](ctx, this.Condition)
+		if err != nil {
+			return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating condition")
+		}
+		if condition.Bool {
+			return ctx.Evaluate(this.Then)
+		}
+		return ctx.Evaluate(this.Else)
+	case *ast.AssertNode:
+		condition, err := evaluateAs[*BoolValue](ctx, this.Condition)
+		if err != nil {
+			return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating assertion")
+		}
+		if !condition.Bool {
+			pos := this.Condition.GetPosition()
+			return nil, errors.NewRecipeError(pos, fmt.Sprintf("assertion `%s` failed", pos.File.Content[pos.Start:pos.End]))
+		}
+		return ctx.Evaluate(this.Target)
 	case *ast.StringNode:
 		builder := ValueBuilder{}
 		for _, content := range this.Content {
-			value, err := ctx.Evaluate(content)
+			value, err := ctx.evaluateString(content)
 			if err != nil {
 				return nil, err
 			}
@@ -325,18 +230,26 @@ func (ctx Scope) Evaluate(currentNode ast.Node) (*StringValue, error) {
 		}
 		return builder.Value(this), nil
 	case *ast.AttrifyNode:
-		target, err := ctx.Evaluate(this.Target)
+		target, err := evaluateAs[*AttrsValue](ctx, this.Target)
 		if err != nil {
 			return nil, errors.WrapRecipeError(err, this.Pos, "while attrifying target")
 		}
 		builder := &ValueBuilder{}
-		for key, value := range target.Attributes {
+		for key, thunk := range target.Attributes.All() {
+			value, err := thunk.Force()
+			if err != nil {
+				return nil, errors.WrapRecipeError(err, this.Pos, "while attrifying target")
+			}
+			str, err := coerceString(thunk.blame(value), value)
+			if err != nil {
+				return nil, errors.WrapRecipeError(err, this.Pos, "while attrifying target")
+			}
 			if builder.Len() > 0 {
 				builder.WriteByte(' ')
 			}
 			builder.WriteString(key)
 			builder.WriteByte('=')
-			builder.WriteValue(value, true)
+			builder.WriteValue(str, true)
 		}
 		return builder.Value(this), nil
 	default:
@@ -0,0 +1,73 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+
+	"friedelschoen.io/paccat/internal/store"
+	"friedelschoen.io/paccat/internal/util"
+)
+
+var sha256Pattern = regexp.MustCompile("^[0-9a-f]{64}$")
+
+var fetchClient = func() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+	return &http.Client{Transport: transport}
+}()
+
+/* fixedPath returns the store-path of content which is known by its sha256 */
+func fixedPath(sha, name string) string {
+	sum := sha256.Sum256([]byte("fixed:sha256:" + sha + ":" + name))
+	return storePath(hex.EncodeToString(sum[:])[:store.HashLength], name)
+}
+
+func urlName(rawurl string) string {
+	parsed, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	return path.Base(parsed.Path)
+}
+
+/* fetchURL downloads rawurl to outpath, the content must match sha */
+func fetchURL(rawurl, sha, outpath string) error {
+	resp, err := fetchClient.Get(rawurl)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to fetch %s: %s", rawurl, resp.Status)
+	}
+
+	file, err := os.CreateTemp(util.GetCachedir(), ".fetch-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name()) /* does nothing if renamed */
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, hash), resp.Body); err != nil {
+		return fmt.Errorf("unable to fetch %s: %v", rawurl, err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != sha {
+		return fmt.Errorf("hash mismatch for %s:\n  expected: %s\n       got: %s", rawurl, sha, got)
+	}
+	if err := file.Chmod(0444); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), outpath); err != nil {
+		return err
+	}
+	return store.Register(outpath, &store.Info{})
+}
@@ -0,0 +1,189 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"friedelschoen.io/paccat/internal/errors"
+	"friedelschoen.io/paccat/internal/parser"
+	"friedelschoen.io/paccat/internal/store"
+	"friedelschoen.io/paccat/internal/util"
+)
+
+/* evalSource parses and evaluates source, the caller sets HOME to a temporary store */
+func evalSource(t *testing.T, source string) (Value, error) {
+	t.Helper()
+	node, err := parser.Parse("test.pcr", source)
+	if err != nil {
+		t.Fatalf("unable to parse `%s`: %v", source, err)
+	}
+	return NewScope(&Options{}).Evaluate(node)
+}
+
+/* errorStart returns the offset of the innermost positioned error of err */
+func errorStart(err error) int {
+	start := -1
+	for err != nil {
+		if positioned, ok := err.(errors.Positioned); ok {
+			start = positioned.GetPosition().Start
+		}
+		ctxErr, ok := err.(errors.ContextError)
+		if !ok {
+			break
+		}
+		err = ctxErr.Previous()
+	}
+	return start
+}
+
+func sha256String(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestFetch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	const content = "hello paccat\n"
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path != "/hello.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	local := filepath.Join(t.TempDir(), "local.txt")
+	if err := os.WriteFile(local, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"http", server.URL + "/hello.txt"},
+		{"file", "file://" + local},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, err := evalSource(t, `fetchurl { url = "`+test.url+`", sha256 = "`+sha256String(content)+`" }`)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			result, ok := value.(*PathValue)
+			if !ok {
+				t.Fatalf("expected store path but got %s", value.TypeName())
+			}
+			if want := fixedPath(sha256String(content), path.Base(test.url)); result.Path != want {
+				t.Errorf("path = %s, want %s", result.Path, want)
+			}
+			if got, err := os.ReadFile(result.Path); err != nil || string(got) != content {
+				t.Errorf("content = %q (%v), want %q", got, err, content)
+			}
+			if !store.IsValid(result.Path) {
+				t.Errorf("%s is not registered as valid", result.Path)
+			}
+		})
+	}
+
+	t.Run("reuse", func(t *testing.T) {
+		before := requests.Load()
+		_, err := evalSource(t, `fetchurl { url = "`+server.URL+`/hello.txt", sha256 = "`+sha256String(content)+`" }`)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if after := requests.Load(); after != before {
+			t.Errorf("valid path was fetched again, %d requests", after-before)
+		}
+	})
+}
+
+func TestFetchErrors(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/hello.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("hello paccat\n"))
+	}))
+	defer server.Close()
+
+	wrongSum := sha256String("something else")
+	tests := []struct {
+		name    string
+		source  string
+		message string
+		at      string /* the error is reported at the first occurrence of at */
+		outpath string /* may not exist after the error */
+	}{
+		{
+			name:    "mismatch",
+			source:  `fetchurl { url = "` + server.URL + `/hello.txt", sha256 = "` + wrongSum + `" }`,
+			message: "hash mismatch",
+			at:      "fetchurl",
+			outpath: fixedPath(wrongSum, "hello.txt"),
+		},
+		{
+			name:    "status",
+			source:  `fetchurl { url = "` + server.URL + `/missing.txt", sha256 = "` + wrongSum + `" }`,
+			message: "404 Not Found",
+			at:      "fetchurl",
+			outpath: fixedPath(wrongSum, "missing.txt"),
+		},
+		{
+			name:    "malformed sha256",
+			source:  `fetchurl { url = "` + server.URL + `/hello.txt", sha256 = "abc" }`,
+			message: "`abc` is not a sha256-hash",
+			at:      `"abc"`,
+		},
+		{
+			name:    "missing sha256",
+			source:  `fetchurl { url = "` + server.URL + `/hello.txt" }`,
+			message: "fetchurl requires field `sha256`",
+			at:      "fetchurl",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := evalSource(t, test.source)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(rootCause(err), test.message) {
+				t.Errorf("error = %q, want %q", rootCause(err), test.message)
+			}
+			if start, want := errorStart(err), strings.Index(test.source, test.at); start != want {
+				t.Errorf("error at offset %d, want %d", start, want)
+			}
+			if test.outpath == "" {
+				return
+			}
+			if _, err := os.Lstat(test.outpath); !os.IsNotExist(err) {
+				t.Errorf("%s exists after failed fetch", test.outpath)
+			}
+			if _, err := os.Lstat(path.Join(util.GetCachedir(), ".meta", path.Base(test.outpath))); !os.IsNotExist(err) {
+				t.Errorf("%s is registered after failed fetch", test.outpath)
+			}
+			entries, _ := os.ReadDir(util.GetCachedir())
+			for _, entry := range entries {
+				if strings.HasPrefix(entry.Name(), ".fetch-") {
+					t.Errorf("temporary download %s is left in the store", entry.Name())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package types
+
+import (
+	"fmt"
+
+	"friedelschoen.io/paccat/internal/ast"
+	"friedelschoen.io/paccat/internal/errors"
+)
+
+/* Builtin is a function implemented in Go, args are in the order of Params and ctx is the scope of the caller */
+type Builtin struct {
+	Name   string
+	Params []string
+	Fn     func(ctx Scope, node ast.Node, args []*Thunk) (Value, error)
+}
+
+/* params returns the names of the parameters in order of declaration */
+func (this *FunctionValue) params() []string {
+	if this.Builtin != nil {
+		return this.Builtin.Params
+	}
+	params := make([]string, len(this.Lambda.Args))
+	for i, pair := range this.Lambda.Args {
+		params[i] = pair.Key.Content
+	}
+	return params
+}
+
+/* invoke calls the function with named arguments, node is the expression of the call */
+func (this *FunctionValue) invoke(ctx Scope, node ast.Node, args map[string]*Thunk) (Value, error) {
+	if this.Builtin != nil {
+		values := make([]*Thunk, len(this.Builtin.Params))
+		for i, param := range this.Builtin.Params {
+			thunk, ok := args[param]
+			if !ok {
+				return nil, errors.NewRecipeError(node.GetPosition(), fmt.Sprintf("%s called without parameter `%s`", this.Builtin.Name, param))
+			}
+			values[i] = thunk
+		}
+		return this.Builtin.Fn(ctx, node, values)
+	}
+
+	/* defaults are evaluated in the scope of the lambda */
+	lambdaCtx := this.Scope
+	var defaults []*Thunk
+	for _, def := range this.Lambda.Args {
+		key := def.Key.Content
+		if thunk, ok := args[key]; ok {
+			lambdaCtx = lambdaCtx.Set(key, thunk)
+		} else if def.Value != nil {
+			thunk := &Thunk{node: def.Value}
+			defaults = append(defaults, thunk)
+			lambdaCtx = lambdaCtx.Set(key, thunk)
+		} else {
+			return nil, errors.NewRecipeError(node.GetPosition(), fmt.Sprintf("lambda called without parameter `%s`", key))
+		}
+	}
+	for _, thunk := range defaults {
+		thunk.scope = lambdaCtx
+	}
+	return lambdaCtx.Evaluate(this.Lambda.Target)
+}
+
+/* apply calls the function with positional arguments, which are bound to the parameters in order */
+func (this *FunctionValue) apply(ctx Scope, node ast.Node, args ...*Thunk) (Value, error) {
+	params := this.params()
+	if len(args) > len(params) {
+		return nil, errors.NewRecipeError(node.GetPosition(), fmt.Sprintf("function takes %d parameters but is called with %d", len(params), len(args)))
+	}
+	named := make(map[string]*Thunk, len(args))
+	for i, arg := range args {
+		named[params[i]] = arg
+	}
+	return this.invoke(ctx, node, named)
+}
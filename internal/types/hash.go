@@ -0,0 +1,82 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"friedelschoen.io/paccat/internal/store"
+	"friedelschoen.io/paccat/internal/util"
+)
+
+func storePath(hash, name string) string {
+	if name != "" {
+		hash += "-" + name
+	}
+	return path.Join(util.GetCachedir(), hash)
+}
+
+/* placeholderPath returns a path of the same length as the final output path, which is substituted after hashing */
+func placeholderPath(output, name string) string {
+	sum := sha256.Sum256([]byte("paccat-placeholder:" + output))
+	return storePath(hex.EncodeToString(sum[:])[:store.HashLength], name)
+}
+
+func writeField(w io.Writer, key, value string) {
+	fmt.Fprintf(w, "%s:%d:%s\n", key, len(value), value)
+}
+
+/* outputPathName returns the name of the store-path of output, outputs other than `out` are suffixed by their name */
+func outputPathName(name, output string) string {
+	switch {
+	case output == "out":
+		return name
+	case name == "":
+		return output
+	default:
+		return name + "-" + output
+	}
+}
+
+/* outputPathHash derives the hash of the store-path of output from the hash of the build */
+func outputPathHash(sum, output string) string {
+	hash := sha256.Sum256([]byte(sum + ":" + output))
+	return hex.EncodeToString(hash[:])[:store.HashLength]
+}
+
+/* outputHash hashes the fully resolved inputs of an output, imported and read files only count through the values they yield */
+func outputHash(name string, outputs []string, interpreter string, args []string, script string, environ map[string]string, deps []string) string {
+	hash := sha256.New()
+	writeField(hash, "name", name)
+	for _, output := range outputs {
+		writeField(hash, "output", output)
+	}
+	writeField(hash, "interpreter", interpreter)
+	for _, arg := range args {
+		writeField(hash, "arg", arg)
+	}
+	writeField(hash, "script", script)
+
+	keys := make([]string, 0, len(environ))
+	for key := range environ {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		writeField(hash, "env", key+"="+environ[key])
+	}
+
+	for _, dep := range deps {
+		writeField(hash, "depends", dep)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\x00")
+}
@@ -0,0 +1,121 @@
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"friedelschoen.io/paccat/internal/ast"
+	"friedelschoen.io/paccat/internal/errors"
+	"friedelschoen.io/paccat/internal/parser"
+)
+
+type parsedKey struct {
+	pathname string /* absolute path */
+	sum      string /* sha256 of the content */
+}
+
+type parsedFile struct {
+	node  ast.Node
+	value Value /* result of the file, set once it is evaluated */
+}
+
+/* parsedFiles caches every parsed file of this process, a changed file is parsed again */
+var parsedFiles = map[parsedKey]*parsedFile{}
+
+/* importStack contains the files of which the evaluation is in progress, in order of import */
+var importStack []string
+
+/* resolvePath resolves name relative to the directory of the file containing node */
+func resolvePath(node ast.Node, name string) string {
+	if path.IsAbs(name) {
+		return name
+	}
+	return path.Join(path.Dir(node.GetPosition().File.Filename), name)
+}
+
+/* parseCached parses pathname unless it has been parsed already with the same content */
+func parseCached(pathname string) (*parsedFile, error) {
+	abspath, err := filepath.Abs(pathname)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(abspath)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	key := parsedKey{abspath, hex.EncodeToString(sum[:])}
+	if file, ok := parsedFiles[key]; ok {
+		return file, nil
+	}
+	node, err := parser.Parse(pathname, string(content))
+	if err != nil {
+		return nil, err
+	}
+	file := &parsedFile{node: node}
+	parsedFiles[key] = file
+	return file, nil
+}
+
+/* evaluateFile evaluates pathname in an empty scope, every file is evaluated once and later imports share its value */
+func (ctx Scope) evaluateFile(pathname string) (Value, error) {
+	file, err := parseCached(pathname)
+	if err != nil {
+		return nil, err
+	}
+	if file.value != nil {
+		return file.value, nil
+	}
+
+	abspath, _ := filepath.Abs(pathname)
+	importStack = append(importStack, abspath)
+	defer func() { importStack = importStack[:len(importStack)-1] }()
+
+	ctx.variables = nil
+	value, err := ctx.Evaluate(file.node)
+	if err != nil {
+		return nil, err
+	}
+	file.value = value
+	return value, nil
+}
+
+/* EvaluateFile evaluates the recipe at pathname */
+func EvaluateFile(options *Options, pathname string) (Value, error) {
+	return NewScope(options).evaluateFile(pathname)
+}
+
+/* evaluateImport evaluates the file imported by this */
+func (ctx Scope) evaluateImport(this *ast.ImportNode) (Value, error) {
+	filename, err := ctx.Evaluate(this.Source)
+	if err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating import")
+	}
+	pathname, err := hostPath(this, this.Source, filename)
+	if err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating import")
+	}
+
+	/* importing a file which is being evaluated would never finish */
+	abspath, err := filepath.Abs(pathname)
+	if err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating import")
+	}
+	for i, current := range importStack {
+		if current == abspath {
+			chain := append(importStack[i:len(importStack):len(importStack)], abspath)
+			return nil, errors.NewRecipeError(this.GetPosition(), "import cycle: "+strings.Join(chain, " -> "))
+		}
+	}
+
+	value, err := ctx.evaluateFile(pathname)
+	if err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), fmt.Sprintf("while importing `%s`", pathname))
+	}
+	return value, nil
+}
@@ -0,0 +1,82 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/* writeRecipes writes every file of recipes into a temporary directory, which is returned */
+func writeRecipes(t *testing.T, recipes map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range recipes {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestImportCycle(t *testing.T) {
+	dir := writeRecipes(t, map[string]string{
+		"c.pcr": "import ./d.pcr",
+		"d.pcr": "{ v = import ./e.pcr }.v",
+		"e.pcr": "import ./c.pcr",
+	})
+	_, err := EvaluateFile(&Options{}, filepath.Join(dir, "c.pcr"))
+	if err == nil {
+		t.Fatal("expected an import cycle")
+	}
+	chain := []string{"c.pcr", "d.pcr", "e.pcr", "c.pcr"}
+	for i := range chain {
+		chain[i] = filepath.Join(dir, chain[i])
+	}
+	if want := "import cycle: " + strings.Join(chain, " -> "); rootCause(err) != want {
+		t.Errorf("error = %q, want %q", rootCause(err), want)
+	}
+}
+
+func TestImportLazyCycle(t *testing.T) {
+	dir := writeRecipes(t, map[string]string{
+		"a.pcr": "{ x = (import ./b.pcr).y }",
+		"b.pcr": "{ y = (import ./a.pcr).x }",
+	})
+	value, err := EvaluateFile(&Options{}, filepath.Join(dir, "a.pcr"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	/* the imports are evaluated after a.pcr is, so the re-import shares the attribute being forced */
+	x, _ := value.(*AttrsValue).Attributes.Get("x")
+	_, err = x.Force()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.HasPrefix(rootCause(err), "infinite recursion") {
+		t.Errorf("error = %q, want infinite recursion", rootCause(err))
+	}
+}
+
+func TestImportShared(t *testing.T) {
+	dir := writeRecipes(t, map[string]string{
+		"main.pcr": "[ import ./lib.pcr, import ./lib.pcr ]",
+		"lib.pcr":  "{ value = 1 }",
+	})
+	value, err := EvaluateFile(&Options{}, filepath.Join(dir, "main.pcr"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	list := value.(*ListValue)
+	first, err := list.Items[0].Force()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := list.Items[1].Force()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Error("a file imported twice is evaluated twice")
+	}
+}
@@ -0,0 +1,265 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"friedelschoen.io/paccat/internal/ast"
+	"friedelschoen.io/paccat/internal/errors"
+)
+
+/* equal compares a and b deeply, values of different types are never equal */
+func equal(node ast.Node, a, b Value) (bool, error) {
+	switch a := a.(type) {
+	case *StringValue:
+		b, ok := b.(*StringValue)
+		return ok && a.Content == b.Content, nil
+	case *PathValue:
+		b, ok := b.(*PathValue)
+		return ok && a.Path == b.Path, nil
+	case *SourceValue:
+		b, ok := b.(*SourceValue)
+		return ok && a.Path == b.Path, nil
+	case *IntValue:
+		b, ok := b.(*IntValue)
+		return ok && a.Number == b.Number, nil
+	case *BoolValue:
+		b, ok := b.(*BoolValue)
+		return ok && a.Bool == b.Bool, nil
+	case *NullValue:
+		_, ok := b.(*NullValue)
+		return ok, nil
+	case *ListValue:
+		b, ok := b.(*ListValue)
+		if !ok || len(a.Items) != len(b.Items) {
+			return false, nil
+		}
+		for i := range a.Items {
+			if eq, err := equalThunks(node, a.Items[i], b.Items[i]); err != nil || !eq {
+				return false, err
+			}
+		}
+		return true, nil
+	case *AttrsValue:
+		b, ok := b.(*AttrsValue)
+		if !ok || a.Attributes.Len() != b.Attributes.Len() {
+			return false, nil
+		}
+		for name, thunk := range a.Attributes.All() {
+			other, ok := b.Attributes.Get(name)
+			if !ok {
+				return false, nil
+			}
+			if eq, err := equalThunks(node, thunk, other); err != nil || !eq {
+				return false, err
+			}
+		}
+		return true, nil
+	default:
+		return false, errors.NewRecipeError(node.GetPosition(), fmt.Sprintf("unable to compare %s", a.TypeName()))
+	}
+}
+
+func equalThunks(node ast.Node, a, b *Thunk) (bool, error) {
+	left, err := a.Force()
+	if err != nil {
+		return false, err
+	}
+	right, err := b.Force()
+	if err != nil {
+		return false, err
+	}
+	return equal(node, left, right)
+}
+
+/* compare orders ints or strings, it returns a negative number if a is less than b */
+func compare(this *ast.BinaryNode, a, b Value) (int, error) {
+	switch left := a.(type) {
+	case *IntValue:
+		right, err := expect[*IntValue](this.Right, b)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case left.Number < right.Number:
+			return -1, nil
+		case left.Number > right.Number:
+			return 1, nil
+		}
+		return 0, nil
+	case *StringValue:
+		right, err := expect[*StringValue](this.Right, b)
+		if err != nil {
+			return 0, err
+		}
+		return strings.Compare(left.Content, right.Content), nil
+	default:
+		return 0, typeError(this.Left, a, "int or string")
+	}
+}
+
+/* concat joins two lists, or two values which can be converted to strings */
+func concat(this *ast.BinaryNode, a, b Value) (Value, error) {
+	if left, ok := a.(*ListValue); ok {
+		right, err := expect[*ListValue](this.Right, b)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]*Thunk, 0, len(left.Items)+len(right.Items))
+		items = append(items, left.Items...)
+		items = append(items, right.Items...)
+		return &ListValue{Node: this, Items: items}, nil
+	}
+	left, err := coerceString(this.Left, a)
+	if err != nil {
+		return nil, err
+	}
+	right, err := coerceString(this.Right, b)
+	if err != nil {
+		return nil, err
+	}
+	builder := ValueBuilder{}
+	builder.WriteValue(left, false)
+	builder.WriteValue(right, false)
+	return builder.Value(this), nil
+}
+
+/* merge returns the attributes of a updated with the attributes of b */
+func merge(this *ast.BinaryNode, a, b Value) (Value, error) {
+	left, err := expect[*AttrsValue](this.Left, a)
+	if err != nil {
+		return nil, err
+	}
+	right, err := expect[*AttrsValue](this.Right, b)
+	if err != nil {
+		return nil, err
+	}
+	attrs := &Attributes{}
+	for name, thunk := range left.Attributes.All() {
+		attrs.Set(name, thunk)
+	}
+	for name, thunk := range right.Attributes.All() {
+		attrs.Set(name, thunk)
+	}
+	return &AttrsValue{Node: this, Attributes: attrs}, nil
+}
+
+/* arithmetic applies an integer operator */
+func arithmetic(this *ast.BinaryNode, a, b Value) (Value, error) {
+	left, err := expect[*IntValue](this.Left, a)
+	if err != nil {
+		return nil, err
+	}
+	right, err := expect[*IntValue](this.Right, b)
+	if err != nil {
+		return nil, err
+	}
+	x, y := left.Number, right.Number
+	result := &IntValue{Node: this}
+	overflow := false
+	switch this.Operator {
+	case "+":
+		result.Number = x + y
+		overflow = (y > 0 && result.Number < x) || (y < 0 && result.Number > x)
+	case "-":
+		result.Number = x - y
+		overflow = (y > 0 && result.Number > x) || (y < 0 && result.Number < x)
+	case "*":
+		result.Number = x * y
+		overflow = x != 0 && (result.Number/x != y || (x == -1 && y == math.MinInt64))
+	case "/":
+		if y == 0 {
+			return nil, errors.NewRecipeError(this.GetPosition(), "division by zero")
+		}
+		result.Number = x / y
+		overflow = x == math.MinInt64 && y == -1
+	}
+	if overflow {
+		return nil, errors.NewRecipeError(this.GetPosition(), "integer overflow")
+	}
+	return result, nil
+}
+
+func (ctx Scope) evaluateBinary(this *ast.BinaryNode) (Value, error) {
+	left, err := ctx.Evaluate(this.Left)
+	if err != nil {
+		return nil, err
+	}
+
+	/* the right-hand side of a logical operator is only evaluated if it is needed */
+	if this.Operator == "&&" || this.Operator == "||" {
+		cond, err := expect[*BoolValue](this.Left, left)
+		if err != nil {
+			return nil, err
+		}
+		if cond.Bool == (this.Operator == "||") {
+			return &BoolValue{Node: this, Bool: cond.Bool}, nil
+		}
+		right, err := evaluateAs[*BoolValue](ctx, this.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &BoolValue{Node: this, Bool: right.Bool}, nil
+	}
+
+	right, err := ctx.Evaluate(this.Right)
+	if err != nil {
+		return nil, err
+	}
+	switch this.Operator {
+	case "+", "-", "*", "/":
+		return arithmetic(this, left, right)
+	case "++":
+		return concat(this, left, right)
+	case "//":
+		return merge(this, left, right)
+	case "==", "!=":
+		eq, err := equal(this, left, right)
+		if err != nil {
+			return nil, err
+		}
+		return &BoolValue{Node: this, Bool: eq == (this.Operator == "==")}, nil
+	case "<", "<=", ">", ">=":
+		cmp, err := compare(this, left, right)
+		if err != nil {
+			return nil, err
+		}
+		result := &BoolValue{Node: this}
+		switch this.Operator {
+		case "<":
+			result.Bool = cmp < 0
+		case "<=":
+			result.Bool = cmp <= 0
+		case ">":
+			result.Bool = cmp > 0
+		case ">=":
+			result.Bool = cmp >= 0
+		}
+		return result, nil
+	default:
+		return nil, errors.NewRecipeError(this.GetPosition(), fmt.Sprintf("unknown operator `%s`", this.Operator))
+	}
+}
+
+func (ctx Scope) evaluateUnary(this *ast.UnaryNode) (Value, error) {
+	switch this.Operator {
+	case "!":
+		value, err := evaluateAs[*BoolValue](ctx, this.Target)
+		if err != nil {
+			return nil, err
+		}
+		return &BoolValue{Node: this, Bool: !value.Bool}, nil
+	case "-":
+		value, err := evaluateAs[*IntValue](ctx, this.Target)
+		if err != nil {
+			return nil, err
+		}
+		if value.Number == math.MinInt64 {
+			return nil, errors.NewRecipeError(this.GetPosition(), "integer overflow")
+		}
+		return &IntValue{Node: this, Number: -value.Number}, nil
+	default:
+		return nil, errors.NewRecipeError(this.GetPosition(), fmt.Sprintf("unknown operator `%s`", this.Operator))
+	}
+}
@@ -0,0 +1,64 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOperators(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string /* representation of the result */
+	}{
+		{`{ a = 1, b = 2 } // { b = 3, c = 4 }`, `{ a = 1, b = 3, c = 4 }`},
+		{`7 / 2 * 2 + 1 - 3`, `4`},
+		{`9223372036854775806 + 1`, `9223372036854775807`},
+		{`-9223372036854775807 - 1`, `-9223372036854775808`},
+		{`(-9223372036854775807 - 1) / 1`, `-9223372036854775808`},
+	}
+	for _, test := range tests {
+		value, err := evalSource(t, test.source)
+		if err != nil {
+			t.Errorf("`%s`: unexpected error: %v", test.source, rootCause(err))
+			continue
+		}
+		got, err := Repr(value)
+		if err != nil {
+			t.Errorf("`%s`: unable to represent result: %v", test.source, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("`%s` = %s, want %s", test.source, got, test.want)
+		}
+	}
+}
+
+func TestOperatorErrors(t *testing.T) {
+	tests := []struct {
+		source  string
+		message string
+		at      string /* the error is reported at the first occurrence of at */
+	}{
+		{`9223372036854775807 + 1`, "integer overflow", "9223372036854775807"},
+		{`1 - 9223372036854775807 - 3`, "integer overflow", "1"},
+		{`4611686018427387904 * 2`, "integer overflow", "4611686018427387904"},
+		{`-1 * (-9223372036854775807 - 1)`, "integer overflow", "-1"},
+		{`(-9223372036854775807 - 1) / -1`, "integer overflow", "-9223372036854775807"},
+		{`-(-9223372036854775807 - 1)`, "integer overflow", "-("},
+		{`1 / 0`, "division by zero", "1"},
+		{`{ a = 1 } // [ 1 ]`, "expected attrset but got list", "["},
+	}
+	for _, test := range tests {
+		_, err := evalSource(t, test.source)
+		if err == nil {
+			t.Errorf("`%s`: expected an error", test.source)
+			continue
+		}
+		if !strings.Contains(rootCause(err), test.message) {
+			t.Errorf("`%s`: error = %q, want %q", test.source, rootCause(err), test.message)
+		}
+		if start, want := errorStart(err), strings.Index(test.source, test.at); start != want {
+			t.Errorf("`%s`: error at offset %d, want %d", test.source, start, want)
+		}
+	}
+}
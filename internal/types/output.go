@@ -0,0 +1,296 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+
+	"friedelschoen.io/paccat/internal/ast"
+	"friedelschoen.io/paccat/internal/errors"
+)
+
+/* forceField forces the attribute key and converts it with as, nil is returned if it is not set */
+func forceField[T Value](fields *Attributes, key string, as func(ast.Node, Value) (T, error)) (T, error) {
+	var zero T
+	thunk, ok := fields.Get(key)
+	if !ok {
+		return zero, nil
+	}
+	value, err := thunk.Force()
+	if err != nil {
+		return zero, err
+	}
+	return as(thunk.blame(value), value)
+}
+
+func (ctx Scope) evaluateOutput(this *ast.OutputNode) (Value, error) {
+	/* the names of the outputs are needed to bind them, so `outputs` is evaluated in the surrounding scope */
+	outputNames := []string{"out"}
+	outputsValue := &ListValue{Node: this, Items: []*Thunk{ValueThunk(literalValue("out"))}}
+	if opt, ok := this.Options.(*ast.DictNode); ok {
+		if pair, ok := opt.Items.Get("outputs"); ok {
+			var err error
+			if outputsValue, err = evaluateAs[*ListValue](ctx, pair.Value); err != nil {
+				return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating outputs")
+			}
+			if outputNames, err = outputList(pair.Value, outputsValue); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	/* the fields of the output can refer to each other and to the outputs */
+	fields := &Attributes{}
+	outputs := make([]*Thunk, len(outputNames))
+	for i := range outputs {
+		outputs[i] = &Thunk{}
+	}
+	bindOutputs := func(ctx Scope) Scope {
+		for i, output := range outputNames {
+			ctx = ctx.Set(output, outputs[i])
+		}
+		return ctx
+	}
+	if opt, ok := this.Options.(*ast.DictNode); ok {
+		for _, pair := range opt.Items {
+			thunk := &Thunk{node: pair.Value}
+			if pair.Key.Content == "outputs" {
+				thunk = ValueThunk(outputsValue)
+			}
+			fields.Set(pair.Key.Content, thunk)
+			ctx = ctx.Set(pair.Key.Content, thunk)
+		}
+		ctx = bindOutputs(ctx)
+		for _, thunk := range fields.All() {
+			if thunk.value == nil {
+				thunk.scope = ctx
+			}
+		}
+		if _, ok := fields.Get("script"); !ok {
+			return nil, errors.NewRecipeError(this.GetPosition(), "output requires field `script`")
+		}
+	} else {
+		ctx = bindOutputs(ctx)
+		fields.Set("script", NewThunk(this.Options, ctx))
+	}
+
+	name := ""
+	if nameValue, err := forceField(fields, "name", coerceString); err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating name")
+	} else if nameValue != nil {
+		if !validName(nameValue.Content) {
+			return nil, errors.NewRecipeError(nameValue.Node.GetPosition(), fmt.Sprintf("invalid output name `%s`", nameValue.Content))
+		}
+		name = nameValue.Content
+	}
+
+	/* the output-paths depend on the script, so the script is evaluated with placeholders */
+	job := build{}
+	for i, output := range outputNames {
+		placeholder := placeholderPath(output, outputPathName(name, output))
+		outputs[i].value = literalValue(placeholder)
+		job.outputs = append(job.outputs, buildOutput{placeholder: placeholder})
+	}
+
+	depends, err := forceField(fields, "depends", asDependencies)
+	if err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating dependencies")
+	}
+	deps, err := dependencyPaths(depends)
+	if err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating dependencies")
+	}
+
+	var exports *Attributes
+	if exp, err := forceField(fields, "exports", expect[*AttrsValue]); err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating exports")
+	} else if exp != nil {
+		/* outputs are attributes of the result as well, so an export may not hide one */
+		for key := range exp.Attributes.All() {
+			if slices.Contains(outputNames, key) {
+				return nil, errors.NewRecipeError(exp.Node.GetPosition(), fmt.Sprintf("export `%s` has the name of an output", key))
+			}
+		}
+		exports = exp.Attributes
+	}
+
+	scriptValue, err := forceField(fields, "script", coerceString)
+	if err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating output")
+	}
+
+	environ, err := dependencyEnviron(deps)
+	if err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating dependencies")
+	}
+	/* values which may refer to store-paths used by the build */
+	references := []Value{scriptValue}
+	for _, dep := range deps {
+		references = append(references, dep)
+		if dep.Exports.Len() > 0 {
+			references = append(references, dep.exportRoot())
+		}
+	}
+	if envValue, err := forceField(fields, "env", expect[*AttrsValue]); err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating environment")
+	} else if envValue != nil {
+		for key := range envValue.Attributes.All() {
+			value, err := forceField(envValue.Attributes, key, coerceString)
+			if err != nil {
+				return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating environment")
+			}
+			environ[key] = value.Content
+			references = append(references, value)
+		}
+	}
+
+	/* the script is passed as file to the interpreter, after args */
+	interpreter := "sh"
+	if value, err := forceField(fields, "interpreter", coerceString); err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating interpreter")
+	} else if value != nil {
+		if value.Content == "" {
+			return nil, errors.NewRecipeError(value.Node.GetPosition(), "interpreter may not be empty")
+		}
+		interpreter = value.Content
+		references = append(references, value)
+	}
+	var args []string
+	if argsValue, err := forceField(fields, "args", expect[*ListValue]); err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating args")
+	} else if argsValue != nil {
+		for _, item := range argsValue.Items {
+			arg, err := forceString(item)
+			if err != nil {
+				return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating args")
+			}
+			args = append(args, arg.Content)
+			references = append(references, arg)
+		}
+	}
+
+	/* the builder also gets the outputs as variables, e.g. $out */
+	for i, output := range outputNames {
+		environ[output] = job.outputs[i].placeholder
+	}
+
+	var deppaths []string
+	for _, dep := range deps {
+		deppaths = append(deppaths, dep.Path)
+	}
+
+	fixedSum := ""
+	if shaValue, err := forceField(fields, "sha256", coerceString); err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating sha256")
+	} else if shaValue != nil {
+		if !sha256Pattern.MatchString(shaValue.Content) {
+			return nil, errors.NewRecipeError(shaValue.Node.GetPosition(), fmt.Sprintf("`%s` is not a sha256-hash", shaValue.Content))
+		}
+		if len(outputNames) != 1 {
+			return nil, errors.NewRecipeError(shaValue.Node.GetPosition(), "fixed-output can only have a single output")
+		}
+		fixedSum = shaValue.Content
+		job.outputs[0].outpath = fixedPath(fixedSum, outputPathName(name, outputNames[0]))
+	} else {
+		sum := outputHash(name, outputNames, interpreter, args, scriptValue.Content, environ, deppaths)
+		for i, output := range outputNames {
+			job.outputs[i].outpath = storePath(outputPathHash(sum, output), outputPathName(name, output))
+		}
+	}
+
+	always := false
+	if alwaysValue, err := forceField(fields, "always", expect[*BoolValue]); err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating output")
+	} else if alwaysValue != nil {
+		always = alwaysValue.Bool
+	}
+
+	job.interpreter = interpreter
+	job.args = args
+	job.script = scriptValue.Content
+	job.environ = environ
+	job.inputs = storeReferences(references...)
+	job.sha256 = fixedSum
+	if err = realise(job.outpaths(), always, func() error { return job.run(ctx.options) }); err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating output")
+	}
+
+	/* every output is a store-path on its own, which knows its siblings */
+	results := &Attributes{}
+	for i, output := range outputNames {
+		results.Set(output, ValueThunk(&PathValue{
+			Node:    this,
+			Path:    job.outputs[i].outpath,
+			Exports: exports,
+			Outputs: results,
+		}))
+	}
+	first, _ := results.Get(outputNames[0])
+	return first.value, nil
+}
+
+/* outputList checks the names of outputs, which must be unique and valid as part of a store-path */
+func outputList(node ast.Node, outputs *ListValue) ([]string, error) {
+	if len(outputs.Items) == 0 {
+		return nil, errors.NewRecipeError(node.GetPosition(), "output requires at least one output")
+	}
+	names := make([]string, len(outputs.Items))
+	for i, item := range outputs.Items {
+		value, err := forceAs[*StringValue](item)
+		if err != nil {
+			return nil, err
+		}
+		if !validName(value.Content) || slices.Contains(names[:i], value.Content) {
+			return nil, errors.NewRecipeError(item.blame(value).GetPosition(), fmt.Sprintf("invalid output `%s`", value.Content))
+		}
+		names[i] = value.Content
+	}
+	return names, nil
+}
+
+func (ctx Scope) evaluateFetch(this *ast.FetchNode) (Value, error) {
+	options, err := evaluateAs[*AttrsValue](ctx, this.Options)
+	if err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating fetchurl")
+	}
+	field := func(key string) (*StringValue, error) {
+		value, err := forceField(options.Attributes, key, coerceString)
+		if err != nil {
+			return nil, errors.WrapRecipeError(err, this.GetPosition(), "while evaluating fetchurl")
+		}
+		return value, nil
+	}
+	urlValue, err := field("url")
+	if err != nil {
+		return nil, err
+	} else if urlValue == nil {
+		return nil, errors.NewRecipeError(this.GetPosition(), "fetchurl requires field `url`")
+	}
+	shaValue, err := field("sha256")
+	if err != nil {
+		return nil, err
+	} else if shaValue == nil {
+		return nil, errors.NewRecipeError(this.GetPosition(), "fetchurl requires field `sha256`")
+	}
+	if !sha256Pattern.MatchString(shaValue.Content) {
+		return nil, errors.NewRecipeError(shaValue.Node.GetPosition(), fmt.Sprintf("`%s` is not a sha256-hash", shaValue.Content))
+	}
+	name := urlName(urlValue.Content)
+	if nameValue, err := field("name"); err != nil {
+		return nil, err
+	} else if nameValue != nil {
+		name = nameValue.Content
+	}
+	if !validName(name) {
+		return nil, errors.NewRecipeError(this.GetPosition(), fmt.Sprintf("invalid output name `%s`", name))
+	}
+
+	outpath := fixedPath(shaValue.Content, name)
+	err = realise([]string{outpath}, false, func() error { return fetchURL(urlValue.Content, shaValue.Content, outpath) })
+	if err != nil {
+		return nil, errors.WrapRecipeError(err, this.GetPosition(), "while fetching")
+	}
+	return &PathValue{
+		Node: this,
+		Path: outpath,
+	}, nil
+}
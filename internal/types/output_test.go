@@ -0,0 +1,120 @@
+package types
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"friedelschoen.io/paccat/internal/store"
+)
+
+func TestOutputs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	value, err := evalSource(t, `let
+    lib = output {
+        name = "lib",
+        outputs = [ "out", "dev" ],
+        exports = { LIBS = "lib" },
+        env = { PATH = "/usr/bin:/bin" },
+        script = ''mkdir -p {{ out }}/lib $dev; echo {{ out }} > {{ dev }}/out''
+    };
+    user = output {
+        name = "user",
+        depends = [ lib.dev ],
+        env = { PATH = "/usr/bin:/bin" },
+        script = ''echo $LIBS > {{ out }}''
+    };
+in { lib = lib, user = user }`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	attrs := value.(*AttrsValue).Attributes
+	libThunk, _ := attrs.Get("lib")
+	lib, err := forceAs[*PathValue](libThunk)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	devThunk, ok := lib.Get("dev")
+	if !ok {
+		t.Fatal("output has no attribute `dev`")
+	}
+	dev := devThunk.value.(*PathValue)
+	if !strings.HasSuffix(dev.Path, "-lib-dev") || store.HashPart(dev.Path) == store.HashPart(lib.Path) {
+		t.Errorf("dev = %s is not a separate store path of %s", dev.Path, lib.Path)
+	}
+	if content, err := os.ReadFile(path.Join(dev.Path, "out")); err != nil || strings.TrimSpace(string(content)) != lib.Path {
+		t.Errorf("dev refers to %q (%v), want %s", content, err, lib.Path)
+	}
+
+	/* exports are relative to the first output, also if another output is depended on */
+	userThunk, _ := attrs.Get("user")
+	user, err := forceAs[*PathValue](userThunk)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if content, err := os.ReadFile(user.Path); err != nil || strings.TrimSpace(string(content)) != lib.Path+"/lib" {
+		t.Errorf("LIBS = %q (%v), want %s/lib", content, err, lib.Path)
+	}
+}
+
+func TestOutputHashOrder(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	script := `output { name = "x", env = { PATH = "/usr/bin:/bin" }, script = ''echo {{ (import ./lib.pcr).config }} > {{ out }}'' }`
+	dir := writeRecipes(t, map[string]string{
+		"main.pcr":   "{ p1 = " + script + ", p2 = " + script + " }",
+		"lib.pcr":    "{ config = builtins.readFile(path = ./config.txt) }",
+		"config.txt": "verbose",
+	})
+	/* p2 shares the value of lib.pcr with p1 if p1 is evaluated first */
+	build := func(attrs ...string) string {
+		t.Helper()
+		parsedFiles = map[parsedKey]*parsedFile{}
+		value, err := EvaluateFile(&Options{}, filepath.Join(dir, "main.pcr"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var result *PathValue
+		for _, attr := range attrs {
+			thunk, _ := value.(*AttrsValue).Attributes.Get(attr)
+			if result, err = forceAs[*PathValue](thunk); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		return result.Path
+	}
+	if alone, after := build("p2"), build("p1", "p2"); alone != after {
+		t.Errorf("p2 = %s if evaluated alone but %s after p1", alone, after)
+	}
+}
+
+func TestOutputsErrors(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	tests := []struct {
+		source  string
+		message string
+		at      string /* the error is reported at the first occurrence of at */
+	}{
+		{`output { outputs = [ "out", "out" ], script = "" }`, "invalid output `out`", `"out" ]`},
+		{`output { outputs = [], script = "" }`, "output requires at least one output", "[]"},
+		{`output { outputs = [ "out", "dev" ], exports = { dev = "include" }, script = "" }`, "export `dev` has the name of an output", "{ dev"},
+		{`output { outputs = [ "out", "dev" ], sha256 = "` + sha256String("") + `", script = "" }`, "fixed-output can only have a single output", `"` + sha256String("")},
+	}
+	for _, test := range tests {
+		_, err := evalSource(t, test.source)
+		if err == nil {
+			t.Errorf("`%s`: expected an error", test.source)
+			continue
+		}
+		if rootCause(err) != test.message {
+			t.Errorf("`%s`: error = %q, want %q", test.source, rootCause(err), test.message)
+		}
+		if start, want := errorStart(err), strings.Index(test.source, test.at); start != want {
+			t.Errorf("`%s`: error at offset %d, want %d", test.source, start, want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package types
+
+import (
+	"fmt"
+	"math"
+
+	"friedelschoen.io/paccat/internal/ast"
+	"friedelschoen.io/paccat/internal/errors"
+	"github.com/agnivade/levenshtein"
+)
+
+const (
+	MaxSimilarityDistance = 3
+	DefaultMaxDepth       = 10000 /* nested evaluations before giving up */
+)
+
+type Variable struct {
+	name  string
+	thunk *Thunk
+}
+
+type Options struct {
+	Sandbox      bool     /* run builds inside namespaces */
+	SandboxPaths []string /* host-paths which are visible inside the sandbox */
+	SearchPath   []string /* entries `name=dir` or `dir` in which <lookups> are searched */
+	MaxDepth     int      /* maximum of nested evaluations, DefaultMaxDepth if zero */
+}
+
+type Scope struct {
+	variables []Variable
+	options   *Options
+}
+
+func NewScope(options *Options) Scope {
+	return Scope{options: options}
+}
+
+func (this Scope) findSimilar(name string) (string, int) {
+	lowest := ""
+	lowestDist := math.MaxInt
+	for _, current := range this.variables {
+		if dist := levenshtein.ComputeDistance(name, current.name); dist < lowestDist {
+			lowest = current.name
+			lowestDist = dist
+		}
+	}
+	for current := range builtins {
+		if dist := levenshtein.ComputeDistance(name, current); dist < lowestDist || (dist == lowestDist && current < lowest) {
+			lowest = current
+			lowestDist = dist
+		}
+	}
+	return lowest, lowestDist
+}
+
+func (ctx Scope) Get(name string) *Thunk {
+	for _, variable := range ctx.variables {
+		if variable.name == name {
+			return variable.thunk
+		}
+	}
+	return builtins[name]
+}
+
+func (ctx Scope) Set(name string, value *Thunk) Scope {
+	variables := make([]Variable, 0, len(ctx.variables)+1)
+	for _, variable := range ctx.variables {
+		if variable.name != name {
+			variables = append(variables, variable)
+		}
+	}
+	if value != nil {
+		variables = append(variables, Variable{name, value})
+	}
+	ctx.variables = variables
+	return ctx
+}
+
+/* Bind binds node to name, node is evaluated in the current scope when it is needed */
+func (ctx Scope) Bind(name string, node ast.Node) Scope {
+	return ctx.Set(name, NewThunk(node, ctx))
+}
+
+/* bindRecursive binds items lazily in a scope which contains the items themselves */
+func (ctx Scope) bindRecursive(items ast.LiteralMap) (Scope, *Attributes) {
+	attrs := &Attributes{}
+	for _, pair := range items {
+		thunk := &Thunk{node: pair.Value}
+		attrs.Set(pair.Key.Content, thunk)
+		ctx = ctx.Set(pair.Key.Content, thunk)
+	}
+	for _, thunk := range attrs.All() {
+		thunk.scope = ctx
+	}
+	return ctx, attrs
+}
+
+func asLiteral(content string) *ast.LiteralNode {
+	return &ast.LiteralNode{
+		Pos: errors.Position{
+			File:  &errors.ErrorFile{Filename: "<eval>", Content: content},
+			Start: 0,
+			End:   len(content)},
+		Content: content,
+	}
+}
+
+func literalValue(content string) *StringValue {
+	return &StringValue{
+		Node:    asLiteral(content),
+		Content: content,
+	}
+}
+
+func (ctx Scope) SetLiteral(name string, content string) Scope {
+	return ctx.Set(name, ValueThunk(literalValue(content)))
+}
+
+func (ctx Scope) lookup(this *ast.ReferenceNode) (*Thunk, error) {
+	thunk := ctx.Get(this.Variable.Content)
+	if thunk == nil {
+		similar, dist := ctx.findSimilar(this.Variable.Content)
+		if dist <= MaxSimilarityDistance {
+			return nil, errors.NewRecipeError(this.GetPosition(), fmt.Sprintf("`%s` is not defined in current scope, do you mean `%s`?", this.Variable.Content, similar))
+		}
+		return nil, errors.NewRecipeError(this.GetPosition(), fmt.Sprintf("`%s` is not defined in current scope", this.Variable.Content))
+	}
+	return thunk, nil
+}
@@ -0,0 +1,214 @@
+package types
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"friedelschoen.io/paccat/internal/ast"
+	"friedelschoen.io/paccat/internal/errors"
+	"friedelschoen.io/paccat/internal/store"
+	"friedelschoen.io/paccat/internal/util"
+)
+
+/* hostPath returns the path on the host which value refers to, strings are relative to the file of node */
+func hostPath(node ast.Node, blame ast.Node, value Value) (string, error) {
+	switch this := value.(type) {
+	case *SourceValue:
+		return this.Path, nil
+	case *PathValue:
+		return this.Path, nil
+	}
+	str, err := coerceString(blame, value)
+	if err != nil {
+		return "", err
+	}
+	return resolvePath(node, str.Content), nil
+}
+
+/* searchPath returns the first file which matches the lookup in the search-path */
+func (ctx Scope) searchPath(this *ast.LookupNode) (Value, error) {
+	name := this.Content.Content
+	var entries []string
+	if ctx.options != nil {
+		entries = ctx.options.SearchPath
+	}
+	for _, entry := range entries {
+		var candidate string
+		if prefix, dir, ok := strings.Cut(entry, "="); !ok {
+			candidate = path.Join(entry, name)
+		} else if name == prefix {
+			candidate = dir
+		} else if rest, ok := strings.CutPrefix(name, prefix+"/"); ok {
+			candidate = path.Join(dir, rest)
+		} else {
+			continue
+		}
+		if _, err := os.Lstat(candidate); err != nil {
+			continue
+		}
+		pathname, err := filepath.Abs(candidate)
+		if err != nil {
+			return nil, errors.WrapRecipeError(err, this.GetPosition(), "unable to resolve path")
+		}
+		return &SourceValue{Node: this, Path: pathname}, nil
+	}
+	return nil, errors.NewRecipeError(this.GetPosition(), fmt.Sprintf("`%s` was not found in the search-path", name))
+}
+
+func fileType(mode fs.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return "regular"
+	case mode.IsDir():
+		return "directory"
+	case mode&fs.ModeSymlink != 0:
+		return "symlink"
+	default:
+		return "unknown"
+	}
+}
+
+/* accept calls the filters with the path and type of a file */
+func (this *SourceValue) accept(pathname string, mode fs.FileMode) (bool, error) {
+	for _, filter := range this.Filters {
+		result, err := filter.apply(this.scope, this.Node,
+			ValueThunk(&StringValue{Node: this.Node, Content: pathname}),
+			ValueThunk(&StringValue{Node: this.Node, Content: fileType(mode)}))
+		if err != nil {
+			return false, err
+		}
+		keep, err := expect[*BoolValue](filter.Node, result)
+		if err != nil {
+			return false, err
+		}
+		if !keep.Bool {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+/* copyTo copies the accepted files of the source to target */
+func (this *SourceValue) copyTo(target string) error {
+	return filepath.WalkDir(this.Path, func(current string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(this.Path, current)
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			if ok, err := this.accept(current, entry.Type()); err != nil {
+				return err
+			} else if !ok && entry.IsDir() {
+				return filepath.SkipDir
+			} else if !ok {
+				return nil
+			}
+		}
+		dest := path.Join(target, rel)
+		switch {
+		case entry.IsDir():
+			return os.Mkdir(dest, 0755)
+		case entry.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(current)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, dest)
+		case entry.Type().IsRegular():
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			/* only the executable-bit is part of the hash */
+			mode := os.FileMode(0644)
+			if info.Mode()&0111 != 0 {
+				mode = 0755
+			}
+			return copyFile(current, dest, mode)
+		default:
+			return fmt.Errorf("%s: unsupported file-type", current)
+		}
+	})
+}
+
+func copyFile(source, dest string, mode os.FileMode) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+/* store copies the source into the store at a path derived from its content, it is copied once per evaluation */
+func (this *SourceValue) store() (*PathValue, error) {
+	if this.stored != nil {
+		return this.stored, nil
+	}
+	name := path.Base(this.Path)
+	if !validName(name) {
+		return nil, fmt.Errorf("invalid output name `%s`", name)
+	}
+
+	temp, err := os.MkdirTemp(util.GetCachedir(), ".source-")
+	if err != nil {
+		return nil, err
+	}
+	defer store.RemovePath(temp)
+	defer util.OnInterrupt(func() { store.RemovePath(temp) })()
+
+	target := path.Join(temp, name)
+	if err := this.copyTo(target); err != nil {
+		return nil, err
+	}
+	sum, err := util.HashPath(target)
+	if err != nil {
+		return nil, err
+	}
+	outpath := storePath(sum[:store.HashLength], name)
+	err = realise([]string{outpath}, false, func() error {
+		if err := os.Rename(target, outpath); err != nil {
+			return err
+		}
+		return store.Register(outpath, &store.Info{})
+	})
+	if err != nil {
+		return nil, err
+	}
+	this.stored = &PathValue{Node: this.Node, Path: outpath}
+	return this.stored, nil
+}
+
+/* builtinFilterSource returns the source at path, only copying the files for which f returns true */
+func builtinFilterSource(ctx Scope, node ast.Node, args []*Thunk) (Value, error) {
+	filter, err := forceAs[*FunctionValue](args[0])
+	if err != nil {
+		return nil, err
+	}
+	source, err := forceAs[*SourceValue](args[1])
+	if err != nil {
+		return nil, err
+	}
+	return &SourceValue{
+		Node:    node,
+		Path:    source.Path,
+		Filters: append(source.Filters[:len(source.Filters):len(source.Filters)], filter),
+		scope:   ctx,
+	}, nil
+}
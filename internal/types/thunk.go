@@ -0,0 +1,94 @@
+package types
+
+import (
+	"fmt"
+
+	"friedelschoen.io/paccat/internal/ast"
+	"friedelschoen.io/paccat/internal/errors"
+)
+
+/* Thunk is a value which is evaluated when it is needed for the first time, later uses share the result */
+type Thunk struct {
+	node       ast.Node
+	scope      Scope
+	value      Value
+	evaluating bool                  /* set while forcing, forcing it again means it depends on itself */
+	compute    func() (Value, error) /* computes the value instead of evaluating node */
+}
+
+func NewThunk(node ast.Node, scope Scope) *Thunk {
+	return &Thunk{node: node, scope: scope}
+}
+
+/* lazyThunk computes its value using fn when it is needed, node is the expression responsible for it */
+func lazyThunk(node ast.Node, fn func() (Value, error)) *Thunk {
+	return &Thunk{node: node, compute: fn}
+}
+
+/* ValueThunk wraps an already evaluated value */
+func ValueThunk(value Value) *Thunk {
+	return &Thunk{value: value}
+}
+
+func (this *Thunk) Force() (Value, error) {
+	if this.value != nil {
+		return this.value, nil
+	}
+	if this.node == nil {
+		return nil, fmt.Errorf("value is not available yet")
+	}
+	if this.evaluating {
+		return nil, &recursionError{this.node.GetPosition(), "infinite recursion, value depends on itself"}
+	}
+	this.evaluating = true
+	var value Value
+	var err error
+	if this.compute != nil {
+		value, err = this.compute()
+	} else {
+		value, err = this.scope.Evaluate(this.node)
+	}
+	this.evaluating = false
+	if err != nil {
+		return nil, err
+	}
+	this.value = value
+	return value, nil
+}
+
+/* blame returns the expression which is responsible for value, which is the result of this */
+func (this *Thunk) blame(value Value) ast.Node {
+	if this.node != nil {
+		return this.node
+	}
+	return value.GetNode()
+}
+
+/* recursionError is raised by a value which depends on itself or by exceeding the evaluation depth */
+type recursionError struct {
+	pos     errors.Position
+	message string
+}
+
+func (this *recursionError) Error() string {
+	return this.message
+}
+
+func (this *recursionError) GetPosition() errors.Position {
+	return this.pos
+}
+
+/* isRecursion reports whether the trace of err ends in a recursionError */
+func isRecursion(err error) bool {
+	for err != nil {
+		if _, ok := err.(*recursionError); ok {
+			return true
+		}
+		ctxErr, ok := err.(errors.ContextError)
+		if !ok {
+			return false
+		}
+		err = ctxErr.Previous()
+	}
+	return false
+}
@@ -2,88 +2,113 @@ package types
 
 import (
 	"iter"
-	"strings"
 
 	"friedelschoen.io/paccat/internal/ast"
 )
 
+/* Value is the result of evaluating an expression */
+type Value interface {
+	TypeName() string  /* name of the type used in errors */
+	GetNode() ast.Node /* expression which produced the value */
+}
+
 type StringValue struct {
 	Node         ast.Node
 	Content      string
 	StringSource []StringSource
-	Attributes   map[string]*StringValue
 }
 
-type StringSource struct {
-	Start int
-	Len   int
-	Value *StringValue /* underlying string-value */
+type IntValue struct {
+	Node   ast.Node
+	Number int64
 }
 
-func (this *StringValue) ValueAt(pos int) *StringValue {
-	for _, item := range this.StringSource {
-		if pos >= item.Start && pos < item.Start+item.Len {
-			return item.Value
-		}
+type BoolValue struct {
+	Node ast.Node
+	Bool bool
+}
+
+type NullValue struct {
+	Node ast.Node
+}
+
+type ListValue struct {
+	Node  ast.Node
+	Items []*Thunk
+}
+
+type AttrsValue struct {
+	Node       ast.Node
+	Attributes *Attributes
+}
+
+/* FunctionValue is either a lambda or a builtin */
+type FunctionValue struct {
+	Node    ast.Node
+	Lambda  *ast.LambdaNode
+	Scope   Scope /* scope in which the lambda is defined */
+	Builtin *Builtin
+}
+
+/* PathValue is a path in the store, it carries the variables exported to dependent outputs */
+type PathValue struct {
+	Node    ast.Node
+	Path    string
+	Exports *Attributes
+	Outputs *Attributes /* every output of the same build by name, including this one */
+}
+
+/* exportRoot returns the first output of the build, the exports are relative to it */
+func (this *PathValue) exportRoot() *PathValue {
+	for _, thunk := range this.Outputs.All() {
+		return thunk.value.(*PathValue)
 	}
 	return this
 }
 
-func (this *StringValue) Split() iter.Seq2[string, *StringValue] {
-	return func(yield func(string, *StringValue) bool) {
-		begin := 0
-		inString := false
-		builder := strings.Builder{}
-
-		for i := 0; i <= len(this.Content); i++ {
-			// Handle end of content for final yield
-			if i == len(this.Content) || (!inString && strings.ContainsRune(" \t\n\r", rune(this.Content[i]))) {
-				if i > begin { // Ensure non-empty substring
-					var value *StringValue = nil
-					for _, item := range this.StringSource {
-						if item.Start <= begin && item.Start+item.Len >= i {
-							value = item.Value
-							break
-						}
-					}
-
-					// Handle string from builder or direct slice
-					part := builder.String()
-					if part == "" {
-						part = this.Content[begin:i]
-					} else {
-						builder.Reset()
-					}
-
-					if !yield(part, value) {
-						return
-					}
-				}
-				begin = i + 1
-			} else if inString {
-				if this.Content[i] == '"' {
-					if i < len(this.Content)-1 && this.Content[i+1] == '"' {
-						// Escaped quote
-						builder.WriteByte('"')
-						i++
-					} else {
-						// End of quoted string
-						inString = false
-					}
-				} else {
-					builder.WriteByte(this.Content[i])
-				}
-			} else {
-				if this.Content[i] == '"' {
-					// Start of quoted string
-					inString = true
-					begin = i + 1
-				}
-			}
-		}
+/* Get returns the output or else the export called name */
+func (this *PathValue) Get(name string) (*Thunk, bool) {
+	if thunk, ok := this.Outputs.Get(name); ok {
+		return thunk, true
 	}
+	return this.Exports.Get(name)
+}
+
+/* SourceValue is a path on the host, it is copied into the store when it is converted to a string */
+type SourceValue struct {
+	Node    ast.Node
+	Path    string           /* absolute path */
+	Filters []*FunctionValue /* every filter must accept a file for it to be copied */
+	scope   Scope            /* scope in which the filters are called */
+	stored  *PathValue       /* set once copied */
 }
 
+type StringSource struct {
+	Start int
+	Len   int
+	Value Value /* underlying value */
+}
+
+func (this *StringValue) TypeName() string   { return "string" }
+func (this *IntValue) TypeName() string      { return "int" }
+func (this *BoolValue) TypeName() string     { return "bool" }
+func (this *NullValue) TypeName() string     { return "null" }
+func (this *ListValue) TypeName() string     { return "list" }
+func (this *AttrsValue) TypeName() string    { return "attrset" }
+func (this *FunctionValue) TypeName() string { return "function" }
+func (this *PathValue) TypeName() string     { return "store path" }
+func (this *SourceValue) TypeName() string   { return "path" }
+
+func (this *StringValue) GetNode() ast.Node   { return this.Node }
+func (this *IntValue) GetNode() ast.Node      { return this.Node }
+func (this *BoolValue) GetNode() ast.Node     { return this.Node }
+func (this *NullValue) GetNode() ast.Node     { return this.Node }
+func (this *ListValue) GetNode() ast.Node     { return this.Node }
+func (this *AttrsValue) GetNode() ast.Node    { return this.Node }
+func (this *FunctionValue) GetNode() ast.Node { return this.Node }
+func (this *PathValue) GetNode() ast.Node     { return this.Node }
+func (this *SourceValue) GetNode() ast.Node   { return this.Node }
+
 func (this *StringValue) FlatSources() iter.Seq[StringSource] {
 	return func(yield func(StringSource) bool) {
 		if !yield(StringSource{0, len(this.Content), this}) {
@@ -93,8 +118,12 @@ func (this *StringValue) FlatSources() iter.Seq[StringSource] {
 			if !yield(source) {
 				return
 			}
-			for child := range source.Value.FlatSources() {
-				if !yield(StringSource{source.Start + child.Start, child.Len, child.Value}) {
+			child, ok := source.Value.(*StringValue)
+			if !ok {
+				continue
+			}
+			for grandchild := range child.FlatSources() {
+				if !yield(StringSource{source.Start + grandchild.Start, grandchild.Len, grandchild.Value}) {
 					return
 				}
 			}
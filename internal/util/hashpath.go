@@ -0,0 +1,76 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+)
+
+/* HashPath returns the sha256 of a file's content, or of a serialization of a directory-tree */
+func HashPath(pathname string) (string, error) {
+	hash := sha256.New()
+	info, err := os.Lstat(pathname)
+	if err != nil {
+		return "", err
+	}
+	if info.Mode().IsRegular() {
+		err = writeContent(hash, pathname)
+	} else {
+		err = writeTree(hash, pathname, info)
+	}
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func writeContent(w io.Writer, pathname string) error {
+	file, err := os.Open(pathname)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(w, file)
+	return err
+}
+
+func writeTree(w io.Writer, pathname string, info os.FileInfo) error {
+	switch {
+	case info.Mode().IsRegular():
+		executable := "-"
+		if info.Mode()&0111 != 0 {
+			executable = "x"
+		}
+		fmt.Fprintf(w, "file %s %d\n", executable, info.Size())
+		return writeContent(w, pathname)
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(pathname)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "link %d %s\n", len(target), target)
+		return nil
+	case info.IsDir():
+		entries, err := os.ReadDir(pathname) /* sorted by filename */
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "dir %d\n", len(entries))
+		for _, entry := range entries {
+			childinfo, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "entry %d %s\n", len(entry.Name()), entry.Name())
+			if err := writeTree(w, path.Join(pathname, entry.Name()), childinfo); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s: unsupported file-type", pathname)
+	}
+}
@@ -0,0 +1,43 @@
+package util
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var (
+	cleanupLock sync.Mutex
+	cleanupID   int
+	cleanups    = map[int]func(){}
+)
+
+/* OnInterrupt registers fn to be run when the process is interrupted, cancel unregisters it */
+func OnInterrupt(fn func()) (cancel func()) {
+	cleanupLock.Lock()
+	defer cleanupLock.Unlock()
+
+	id := cleanupID
+	cleanupID++
+	cleanups[id] = fn
+	return func() {
+		cleanupLock.Lock()
+		defer cleanupLock.Unlock()
+		delete(cleanups, id)
+	}
+}
+
+/* HandleInterrupt runs the registered cleanups on SIGINT or SIGTERM and exits */
+func HandleInterrupt() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cleanupLock.Lock()
+		for _, fn := range cleanups {
+			fn()
+		}
+		os.Exit(130)
+	}()
+}